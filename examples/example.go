@@ -22,6 +22,7 @@ func main() {
 	config := otel.Config{
 		Host:         "localhost:5081",
 		Token:        "cm9vdEBleGFtcGxlLmNvbTpDb21wbGV4cGFzcyMxMjM=",
+		AuthScheme:   "Basic",
 		ServiceName:  "example-service3",
 		Environment:  "development",
 		Organization: "example-org3",
@@ -42,10 +43,12 @@ func main() {
 	}()
 
 	// Set up slog with OpenTelemetry handler
-	jsonHandler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})
-	logger := slog.New(jsonHandler)
-	otelLogger := slog.New(otel.NewOtelHandler(logger, config.ServiceName))
-	slog.SetDefault(otelLogger)
+	otelHandler, err := otel.NewOtelHandlerWithOptions(config.ServiceName, otel.FormatJSON, os.Stdout, otel.WithLevel(otelClient.LogLevel()))
+	if err != nil {
+		slog.Error("Failed to create OpenTelemetry log handler", "error", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(slog.New(otelHandler))
 
 	// Test log to verify ingestion
 	slog.InfoContext(ctx, "Test log from main", "app", "example-service")
@@ -54,7 +57,7 @@ func main() {
 	time.Sleep(2 * time.Second)
 
 	// Initialize metrics recorder
-	metrics, err := otel.NewMetricsRecorder(otelClient.GetMeterProvider(), "example-service")
+	metrics, err := otelClient.MetricsRecorder("example-service")
 	if err != nil {
 		slog.Error("Failed to create metrics recorder", "error", err)
 		os.Exit(1)
@@ -64,11 +67,19 @@ func main() {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/hello", handleHello(otelClient, metrics))
 
-	// Start HTTP server
+	// Admin endpoints are unauthenticated, so they only listen on localhost
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/admin/loglevel", otelClient.LogLevelHandler())
+
+	// Start HTTP servers
 	server := &http.Server{
 		Addr:    ":8080",
 		Handler: mux,
 	}
+	adminServer := &http.Server{
+		Addr:    "localhost:9090",
+		Handler: adminMux,
+	}
 
 	go func() {
 		slog.Info("Starting HTTP server on :8080")
@@ -77,6 +88,13 @@ func main() {
 			os.Exit(1)
 		}
 	}()
+	go func() {
+		slog.Info("Starting admin server on localhost:9090")
+		if err := adminServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Admin server failed", "error", err)
+			os.Exit(1)
+		}
+	}()
 
 	// Wait for shutdown signal
 	<-ctx.Done()
@@ -88,13 +106,16 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		slog.Error("Server shutdown failed", "error", err)
 	}
+	if err := adminServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Admin server shutdown failed", "error", err)
+	}
 	slog.Info("Server stopped")
 }
 
 func handleHello(otelClient *otel.Otel, metrics *otel.MetricsRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Start a new span
-		ctx, span := otel.StartSpan(r.Context(), otelClient.GetTracerProvider(), "handleHello")
+		ctx, span := otelClient.StartSpan(r.Context(), "handleHello")
 		defer span.End()
 
 		// Record latency
@@ -115,7 +136,7 @@ func handleHello(otelClient *otel.Otel, metrics *otel.MetricsRecorder) http.Hand
 			err := errors.New("simulated request failure")
 			slog.ErrorContext(ctx, "Request failed", "error", err)
 			otel.RecordTraceError(err, "example-service", span)
-			metrics.RecordFailedRequest(ctx, attribute.String("endpoint", "/hello"))
+			metrics.RecordFailedRequest(ctx, attribute.String("endpoint", "/hello"), otel.ErrorClass(err))
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
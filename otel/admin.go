@@ -0,0 +1,82 @@
+package otel
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// LogLevelHandler returns an admin endpoint for the runtime log level. GET
+// reports the current level; PUT or POST with a level query parameter (e.g.
+// ?level=DEBUG) changes it. Mount it on an admin port only.
+func (o *Otel) LogLevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var level slog.Level
+			if err := level.UnmarshalText([]byte(r.URL.Query().Get("level"))); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			o.SetLogLevel(level)
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintln(w, o.logLevel.Level())
+	})
+}
+
+// PrometheusHandler returns the Prometheus scrape endpoint without
+// authentication, e.g. to mount on /metrics of an internal port. Scrapers that
+// negotiate OpenMetrics also receive exemplars. It requires
+// Config.MetricsExporter "prometheus" or Config.PrometheusScrape and responds
+// with 404 otherwise.
+func (o *Otel) PrometheusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry := o.prometheusRegistry()
+		if registry == nil {
+			http.Error(w, "otel: Prometheus scraping is not enabled", http.StatusNotFound)
+			return
+		}
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, r)
+	})
+}
+
+// ScrapeHandler returns a Prometheus scrape endpoint guarded by a bearer token,
+// compared in constant time. Requests without the token get 401; an empty token
+// rejects every request. It requires Config.PrometheusScrape and responds with
+// 404 otherwise.
+func (o *Otel) ScrapeHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registry := o.prometheusRegistry()
+		if registry == nil {
+			http.Error(w, "otel: Prometheus scraping is not enabled", http.StatusNotFound)
+			return
+		}
+
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, r)
+	})
+}
+
+// prometheusRegistry returns the registry of the current meter provider, if it
+// exposes Prometheus metrics
+func (o *Otel) prometheusRegistry() *prometheus.Registry {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	return o.promRegistry
+}
@@ -0,0 +1,84 @@
+package otel
+
+import (
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var (
+	_ propagation.TextMapCarrier = (*KafkaHeaderCarrier)(nil)
+	_ propagation.TextMapCarrier = AMQPTableCarrier(nil)
+)
+
+// KafkaHeader is a Kafka record header. Its fields match the header types of
+// franz-go (kgo.RecordHeader), confluent-kafka-go and kafka-go (kafka.Header),
+// so their headers convert one by one. sarama's RecordHeader holds the key as
+// a []byte instead; convert its headers with
+// KafkaHeader{Key: string(h.Key), Value: h.Value} and back with
+// sarama.RecordHeader{Key: []byte(h.Key), Value: h.Value}.
+type KafkaHeader struct {
+	Key   string
+	Value []byte
+}
+
+// KafkaHeaderCarrier adapts Kafka record headers to propagation.TextMapCarrier.
+// Use a pointer so Set can append headers.
+type KafkaHeaderCarrier []KafkaHeader
+
+// Get returns the value of the first header with the key
+func (c *KafkaHeaderCarrier) Get(key string) string {
+	for _, h := range *c {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces the value of the header with the key or appends a new header
+func (c *KafkaHeaderCarrier) Set(key, value string) {
+	for i, h := range *c {
+		if h.Key == key {
+			(*c)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c = append(*c, KafkaHeader{Key: key, Value: []byte(value)})
+}
+
+// Keys lists the header keys
+func (c *KafkaHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c))
+	for _, h := range *c {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// AMQPTableCarrier adapts AMQP message headers to propagation.TextMapCarrier.
+// amqp091-go's amqp.Table converts directly: AMQPTableCarrier(msg.Headers).
+type AMQPTableCarrier map[string]any
+
+// Get returns the header value when it is a string or byte slice
+func (c AMQPTableCarrier) Get(key string) string {
+	switch v := c[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// Set stores the value as a string header
+func (c AMQPTableCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Keys lists the header keys
+func (c AMQPTableCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
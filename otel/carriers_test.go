@@ -0,0 +1,62 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCarriersRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		carrier propagation.TextMapCarrier
+	}{
+		{"kafka", &KafkaHeaderCarrier{{Key: "message-id", Value: []byte("42")}}},
+		{"amqp", AMQPTableCarrier{"message-id": "42"}},
+	}
+	propagator := propagation.TraceContext{}
+	want := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 0x0b},
+		SpanID:     trace.SpanID{0x0c},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			propagator.Inject(trace.ContextWithSpanContext(context.Background(), want), tt.carrier)
+			got := trace.SpanContextFromContext(propagator.Extract(context.Background(), tt.carrier))
+
+			if !got.Equal(want.WithRemote(true)) {
+				t.Errorf("extracted %v, want %v", got, want)
+			}
+			if tt.carrier.Get("message-id") != "42" {
+				t.Errorf("existing header = %q, want %q", tt.carrier.Get("message-id"), "42")
+			}
+			if keys := tt.carrier.Keys(); len(keys) != 2 {
+				t.Errorf("keys = %v, want message-id and traceparent", keys)
+			}
+		})
+	}
+}
+
+func TestKafkaHeaderCarrierSetReplaces(t *testing.T) {
+	carrier := KafkaHeaderCarrier{{Key: "traceparent", Value: []byte("old")}}
+	carrier.Set("traceparent", "new")
+
+	if len(carrier) != 1 || string(carrier[0].Value) != "new" {
+		t.Errorf("headers = %v, want a single traceparent header with value new", carrier)
+	}
+}
+
+func TestAMQPTableCarrierBytes(t *testing.T) {
+	carrier := AMQPTableCarrier{"traceparent": []byte("value"), "retries": int32(3)}
+
+	if got := carrier.Get("traceparent"); got != "value" {
+		t.Errorf("Get(traceparent) = %q, want %q", got, "value")
+	}
+	if got := carrier.Get("retries"); got != "" {
+		t.Errorf("Get(retries) = %q, want empty for a non-string value", got)
+	}
+}
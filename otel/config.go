@@ -0,0 +1,320 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Config holds configuration parameters for Otel initialization
+type Config struct {
+	Host           string // Collector host:port, or unix:///path/to.sock for a local gRPC socket
+	Token          string
+	AuthScheme     string // Authorization header scheme, e.g. "Basic" or "Bearer"; empty sends Token verbatim
+	ServiceName    string
+	ServiceVersion string // Reported as the service.version resource attribute
+	Environment    string
+	Organization   string
+	StreamName     string
+	SampleRate     float64    // Sampling rate for traces (0 to 1; 0 disables sampling)
+	Compression    string     // OTLP payload compression: "gzip" or "none" (default)
+	UserAgent      string     // User-agent sent by the OTLP exporters; defaults to the SDK's
+	LogLevel       slog.Level // Initial minimum level of handlers using Otel.LogLevel (default Info)
+
+	// Headers are sent with every OTLP export in addition to the built-in
+	// Authorization, organization and stream-name headers, which they override
+	// on key collisions (e.g. a tenant ID or an API key under another name)
+	Headers map[string]string
+
+	// Protocol is the OTLP transport: "grpc" (default) or "http" (protobuf
+	// over HTTP, e.g. through a gateway that only forwards HTTP)
+	Protocol string
+	// TracePath, MetricPath and LogPath override the URL path of each signal
+	// with the "http" protocol (defaults /v1/traces, /v1/metrics and /v1/logs),
+	// e.g. "/otlp/v1/traces" behind a reverse proxy; ignored for "grpc"
+	TracePath  string
+	MetricPath string
+	LogPath    string
+
+	// Retry is the retry policy shared by the OTLP exporters of all signals
+	// (default: enabled, 1s initial interval, 10s max interval, 30s max elapsed)
+	Retry *RetryConfig
+
+	// Exporter selects where telemetry is sent: "otlp" (default) exports to
+	// Host, "stdout" pretty-prints every signal to StdoutWriter for local
+	// development and tests, in which case Host and Token are not required
+	Exporter string
+	// MetricsExporter overrides Exporter for metrics. "prometheus" replaces the
+	// push exporter with a pull endpoint served by Otel.PrometheusHandler.
+	MetricsExporter string
+	// Views change how matching instruments are aggregated or drop them, e.g.
+	// an exponential histogram for latency:
+	//
+	//	sdkmetric.NewView(
+	//		sdkmetric.Instrument{Name: "*_duration_seconds"},
+	//		sdkmetric.Stream{Aggregation: sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}},
+	//	)
+	Views []sdkmetric.View
+
+	// MetricTemporality is the temporality of pushed metrics: "cumulative"
+	// (default) or "delta" for backends that expect per-interval values.
+	// Up-down counters stay cumulative; the Prometheus endpoint is always
+	// cumulative.
+	MetricTemporality string
+	// ExemplarFilter selects which measurements carry exemplars linking them to
+	// the active trace: "trace_based" (default; sampled spans only),
+	// "always_on" or "always_off". Backends must store exemplars to show
+	// them, e.g. Prometheus with --enable-feature=exemplar-storage.
+	ExemplarFilter string
+	// StdoutWriter receives the output of the stdout exporter (default os.Stdout)
+	StdoutWriter io.Writer
+
+	// Disabled turns telemetry off, e.g. in CI: Setup installs providers that
+	// record nothing and skips validation, so instrumented code runs unchanged.
+	// Also enabled by OTEL_SDK_DISABLED=true.
+	Disabled bool
+
+	// BestEffort lets Setup continue when a provider fails to initialize, so
+	// the remaining signals still work; Setup then returns the collected errors
+	BestEffort bool
+
+	// OverwriteGlobals replaces global providers installed by another library.
+	// By default Setup keeps them and only installs its own providers where the
+	// globals are unset; either way a foreign provider is reported to the
+	// global error handler.
+	OverwriteGlobals bool
+
+	// ShutdownTimeout bounds the shutdown of each provider (default 10s)
+	ShutdownTimeout time.Duration
+
+	// ResourceAttributes are added to the resource of every signal and take
+	// precedence over the built-in attributes on key collisions
+	ResourceAttributes map[string]string
+	// DetectResources adds host and container attributes and honors
+	// OTEL_RESOURCE_ATTRIBUTES. Detection failures are reported to the global
+	// error handler and do not abort Setup.
+	DetectResources bool
+
+	// DefaultSpanAttributes are set on every span started from the tracer
+	// provider (StartSpan, the messaging helpers, middleware) unless the span
+	// sets the same key itself. They are not added to the resource.
+	DefaultSpanAttributes []attribute.KeyValue
+
+	// PrometheusScrape additionally exposes metrics for pulling through
+	// Otel.ScrapeHandler, alongside the OTLP push exporter
+	PrometheusScrape bool
+
+	// Propagators selects the context propagation formats: "tracecontext",
+	// "baggage", "b3" (single header), "b3multi" and "jaeger". Defaults to
+	// tracecontext and baggage.
+	Propagators []string
+
+	// SamplePreset picks the sample rate from Environment when SampleRate is
+	// zero: 1 for "development", 0.5 for "staging" and 0.05 for "production".
+	// Other environments keep sampling everything; an explicit SampleRate
+	// always wins.
+	SamplePreset bool
+
+	// Sampling overrides how the ratio sampler treats spans with a parent
+	Sampling SamplingOptions
+
+	// Sampler, when set, is used as the trace sampler verbatim and overrides
+	// SampleRate, e.g. to plug in a rule-based or composite sampler
+	Sampler sdktrace.Sampler
+
+	// UseSimpleSpanProcessor exports each span synchronously when it ends
+	// instead of batching, so spans show up immediately in tests and while
+	// debugging. It slows down every span end; keep the batcher in production.
+	// The trace queue settings below only apply to the batcher.
+	UseSimpleSpanProcessor bool
+
+	// TraceQueueThreshold is the fraction of the trace batch queue (0 to 1) at
+	// which OnTraceQueueSaturation fires; 0 disables the callback
+	TraceQueueThreshold float64
+	// OnTraceQueueSaturation is called when the trace queue depth crosses
+	// TraceQueueThreshold, before spans start being dropped. It runs on the
+	// goroutine ending the span and must not block.
+	OnTraceQueueSaturation func(depth, capacity int)
+}
+
+// samplePresets are the sample rates per Environment used with SamplePreset
+var samplePresets = map[string]float64{
+	"development": 1,
+	"staging":     0.5,
+	"production":  0.05,
+}
+
+// sampleRate returns SampleRate, or the preset for Environment when
+// SamplePreset is set and SampleRate is zero
+func (c Config) sampleRate() float64 {
+	if c.SampleRate == 0 && c.SamplePreset {
+		return samplePresets[strings.ToLower(c.Environment)]
+	}
+	return c.SampleRate
+}
+
+// SamplingOptions overrides the delegates of the parent-based sampler used with
+// SampleRate, e.g. to honor upstream decisions while sampling local roots at a
+// ratio. Nil fields keep the default of following the parent's decision.
+type SamplingOptions struct {
+	RemoteParentSampled    sdktrace.Sampler
+	RemoteParentNotSampled sdktrace.Sampler
+	LocalParentSampled     sdktrace.Sampler
+	LocalParentNotSampled  sdktrace.Sampler
+}
+
+// parentBasedOptions converts the set fields to sdktrace.ParentBased options
+func (s SamplingOptions) parentBasedOptions() []sdktrace.ParentBasedSamplerOption {
+	var opts []sdktrace.ParentBasedSamplerOption
+	if s.RemoteParentSampled != nil {
+		opts = append(opts, sdktrace.WithRemoteParentSampled(s.RemoteParentSampled))
+	}
+	if s.RemoteParentNotSampled != nil {
+		opts = append(opts, sdktrace.WithRemoteParentNotSampled(s.RemoteParentNotSampled))
+	}
+	if s.LocalParentSampled != nil {
+		opts = append(opts, sdktrace.WithLocalParentSampled(s.LocalParentSampled))
+	}
+	if s.LocalParentNotSampled != nil {
+		opts = append(opts, sdktrace.WithLocalParentNotSampled(s.LocalParentNotSampled))
+	}
+	return opts
+}
+
+// withEnv returns a copy of the configuration with empty fields filled from the
+// standard OTEL environment variables; explicit values take precedence
+func (c Config) withEnv() Config {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("OTEL_SDK_DISABLED")), "true") {
+		c.Disabled = true
+	}
+	if c.Host == "" {
+		c.Host = endpointHost(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	}
+	if c.ServiceName == "" {
+		c.ServiceName = os.Getenv("OTEL_SERVICE_NAME")
+	}
+
+	envHeaders := parseEnvHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if len(envHeaders) == 0 {
+		return c
+	}
+	headers := maps.Clone(c.Headers)
+	if headers == nil {
+		headers = make(map[string]string, len(envHeaders))
+	}
+	for k, v := range envHeaders {
+		if !c.hasHeader(k) {
+			headers[k] = v
+		}
+	}
+	c.Headers = headers
+	return c
+}
+
+// hasHeader reports whether the configuration sets the header, either through
+// Headers or one of the fields behind the built-in headers
+func (c Config) hasHeader(name string) bool {
+	for k := range c.Headers {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	switch strings.ToLower(name) {
+	case "authorization":
+		return c.Token != ""
+	case "organization":
+		return c.Organization != ""
+	case "stream-name":
+		return c.StreamName != ""
+	}
+	return false
+}
+
+// endpointHost converts an OTEL_EXPORTER_OTLP_ENDPOINT URL such as
+// "http://collector:4317" to the host:port form of Host
+func endpointHost(endpoint string) string {
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return endpoint
+}
+
+// parseEnvHeaders parses the "key1=value1,key2=value2" format of
+// OTEL_EXPORTER_OTLP_HEADERS, whose values are URL-encoded. Malformed entries
+// are skipped.
+func parseEnvHeaders(s string) map[string]string {
+	headers := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(entry, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		headers[k] = value
+	}
+	return headers
+}
+
+// validate checks that the configuration is usable and reports every problem found
+func (c Config) validate() error {
+	var errs []error
+	switch c.Exporter {
+	case "", ExporterOTLP:
+		if c.Host == "" {
+			errs = append(errs, errors.New("otel: Host must be set"))
+		}
+		if !c.hasHeader("Authorization") {
+			errs = append(errs, errors.New("otel: Token or an Authorization header must be set"))
+		}
+	case ExporterStdout:
+	default:
+		errs = append(errs, fmt.Errorf("otel: Exporter must be %q or %q, got %q", ExporterOTLP, ExporterStdout, c.Exporter))
+	}
+	if c.Protocol != "" && c.Protocol != ProtocolGRPC && c.Protocol != ProtocolHTTP {
+		errs = append(errs, fmt.Errorf("otel: Protocol must be %q or %q, got %q", ProtocolGRPC, ProtocolHTTP, c.Protocol))
+	}
+	if c.MetricTemporality != "" && c.MetricTemporality != TemporalityCumulative && c.MetricTemporality != TemporalityDelta {
+		errs = append(errs, fmt.Errorf("otel: MetricTemporality must be %q or %q, got %q", TemporalityCumulative, TemporalityDelta, c.MetricTemporality))
+	}
+	switch c.ExemplarFilter {
+	case "", "trace_based", "always_on", "always_off":
+	default:
+		errs = append(errs, fmt.Errorf("otel: ExemplarFilter must be \"trace_based\", \"always_on\" or \"always_off\", got %q", c.ExemplarFilter))
+	}
+	if strings.HasPrefix(c.Host, "unix://") && c.Protocol == ProtocolHTTP {
+		errs = append(errs, errors.New("otel: unix:// hosts require the grpc protocol"))
+	}
+	if c.MetricsExporter != "" && c.MetricsExporter != ExporterPrometheus {
+		errs = append(errs, fmt.Errorf("otel: MetricsExporter must be %q, got %q", ExporterPrometheus, c.MetricsExporter))
+	}
+	if c.ServiceName == "" {
+		errs = append(errs, errors.New("otel: ServiceName must be set"))
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("otel: SampleRate must be between 0 and 1, got %v", c.SampleRate))
+	}
+	if c.Compression != "" && c.Compression != "gzip" && c.Compression != "none" {
+		errs = append(errs, fmt.Errorf("otel: Compression must be \"gzip\" or \"none\", got %q", c.Compression))
+	}
+	if _, err := newPropagator(c.Propagators); err != nil {
+		errs = append(errs, err)
+	}
+	if c.TraceQueueThreshold < 0 || c.TraceQueueThreshold > 1 {
+		errs = append(errs, fmt.Errorf("otel: TraceQueueThreshold must be between 0 and 1, got %v", c.TraceQueueThreshold))
+	}
+	return errors.Join(errs...)
+}
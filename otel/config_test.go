@@ -0,0 +1,106 @@
+package otel
+
+import "testing"
+
+func TestConfigEnvFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		config      Config
+		wantHost    string
+		wantService string
+		wantHeaders map[string]string
+	}{
+		{
+			name: "env fills empty fields",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317",
+				"OTEL_SERVICE_NAME":           "env-service",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "Authorization=Bearer%20secret,x-tenant=acme",
+			},
+			wantHost:    "collector:4317",
+			wantService: "env-service",
+			wantHeaders: map[string]string{"Authorization": "Bearer secret", "x-tenant": "acme"},
+		},
+		{
+			name: "config takes precedence",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317",
+				"OTEL_SERVICE_NAME":           "env-service",
+				"OTEL_EXPORTER_OTLP_HEADERS":  "authorization=env-token,x-tenant=env,x-extra=1",
+			},
+			config: Config{
+				Host:        "localhost:5081",
+				ServiceName: "config-service",
+				Token:       "config-token",
+				Headers:     map[string]string{"X-Tenant": "config"},
+			},
+			wantHost:    "localhost:5081",
+			wantService: "config-service",
+			wantHeaders: map[string]string{"Authorization": "config-token", "X-Tenant": "config", "x-extra": "1"},
+		},
+		{
+			name:        "lowercase authorization without token",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "authorization=Bearer%20x"},
+			wantHeaders: map[string]string{"authorization": "Bearer x"},
+		},
+		{
+			name:        "endpoint without scheme",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4317"},
+			wantHost:    "collector:4317",
+			wantHeaders: map[string]string{},
+		},
+		{
+			name:        "malformed headers are skipped",
+			env:         map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "novalue,=empty,ok=1,bad=%zz"},
+			wantHeaders: map[string]string{"ok": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_SERVICE_NAME", "OTEL_EXPORTER_OTLP_HEADERS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			o := New(tt.config)
+			if o.config.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", o.config.Host, tt.wantHost)
+			}
+			if o.config.ServiceName != tt.wantService {
+				t.Errorf("ServiceName = %q, want %q", o.config.ServiceName, tt.wantService)
+			}
+
+			headers := o.commonHeaders()
+			for k, v := range tt.wantHeaders {
+				if headers[k] != v {
+					t.Errorf("header %s = %q, want %q", k, headers[k], v)
+				}
+			}
+			for _, k := range []string{"Authorization", "authorization", "x-tenant", "novalue", "", "bad"} {
+				if _, want := tt.wantHeaders[k]; !want {
+					if v, ok := headers[k]; ok {
+						t.Errorf("unexpected header %s = %q", k, v)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestConfigEnvDisabled(t *testing.T) {
+	t.Setenv("OTEL_SDK_DISABLED", "TRUE")
+
+	if o := New(Config{}); !o.config.Disabled {
+		t.Error("OTEL_SDK_DISABLED=TRUE did not set Disabled")
+	}
+}
+
+func TestConfigEnvDoesNotModifyHeaders(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "x-extra=1")
+	headers := map[string]string{"x-tenant": "acme"}
+
+	New(Config{Headers: headers})
+	if len(headers) != 1 {
+		t.Errorf("caller's Headers were modified: %v", headers)
+	}
+}
@@ -0,0 +1,59 @@
+package otel
+
+import (
+	"sync"
+	"time"
+)
+
+// dedupPruneSize is the number of tracked keys above which expired entries are pruned
+const dedupPruneSize = 1024
+
+// dedupCache tracks recently emitted log keys shared by a handler and its derivatives
+type dedupCache struct {
+	window  time.Duration
+	mu      sync.Mutex
+	entries map[string]*dedupEntry
+}
+
+// dedupEntry is the state of a key within its current window
+type dedupEntry struct {
+	start      time.Time
+	suppressed int
+}
+
+// newDedupCache creates a cache suppressing repeats within window
+func newDedupCache(window time.Duration) *dedupCache {
+	return &dedupCache{
+		window:  window,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+// check reports whether a record with the key should be emitted at now and,
+// if so, how many repeats were suppressed in the previous window
+func (c *dedupCache) check(key string, now time.Time) (suppressed int, emit bool) {
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		if now.Sub(e.start) < c.window {
+			e.suppressed++
+			return 0, false
+		}
+		suppressed = e.suppressed
+	}
+
+	if len(c.entries) >= dedupPruneSize {
+		for k, e := range c.entries {
+			if now.Sub(e.start) >= c.window {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = &dedupEntry{start: now}
+	return suppressed, true
+}
@@ -0,0 +1,86 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error classes returned by Classify
+const (
+	ErrorClassRetryable = "retryable"
+	ErrorClassClient    = "client"
+	ErrorClassServer    = "server"
+)
+
+// ErrorClassKey is the attribute key carrying the class of an error
+const ErrorClassKey = attribute.Key("error.class")
+
+// Classify sorts an error into ErrorClassRetryable, ErrorClassClient or
+// ErrorClassServer so it can be sliced the same way in spans, logs and metrics.
+// Deadlines, timeouts, temporary errors and transient gRPC codes are retryable;
+// cancellations and gRPC codes blaming the caller are client errors; anything
+// else is a server error. It returns "" for a nil error.
+func Classify(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassRetryable
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassClient
+	}
+
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return ErrorClassRetryable
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return ErrorClassRetryable
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+			return ErrorClassRetryable
+		case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+			codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+			return ErrorClassClient
+		}
+	}
+	return ErrorClassServer
+}
+
+// ErrorClass returns the error.class attribute for err, e.g. to pass to
+// MetricsRecorder.RecordFailedRequest
+func ErrorClass(err error) attribute.KeyValue {
+	return ErrorClassKey.String(Classify(err))
+}
+
+// ExporterInitError reports an exporter that failed to initialize during Setup
+// or Reconfigure. Use errors.As to tell which signal failed and inspect the
+// underlying error, e.g. to decide whether to retry Setup or fail fast.
+type ExporterInitError struct {
+	// Signal is "logs", "metrics" or "traces"
+	Signal string
+	// Endpoint is the collector host, or the exporter name for the stdout and
+	// Prometheus exporters
+	Endpoint string
+	Err      error
+}
+
+// Error implements error
+func (e *ExporterInitError) Error() string {
+	return fmt.Sprintf("otel: initializing %s exporter for %s: %v", e.Signal, e.Endpoint, e.Err)
+}
+
+// Unwrap returns the underlying exporter error
+func (e *ExporterInitError) Unwrap() error {
+	return e.Err
+}
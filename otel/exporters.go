@@ -0,0 +1,288 @@
+package otel
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+)
+
+// Exporters selectable with Config.Exporter
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+
+	// ExporterPrometheus is only valid for Config.MetricsExporter
+	ExporterPrometheus = "prometheus"
+)
+
+// Temporalities selectable with Config.MetricTemporality
+const (
+	TemporalityCumulative = "cumulative"
+	TemporalityDelta      = "delta"
+)
+
+// temporalitySelector returns the temporality selector of the push exporters
+func (o *Otel) temporalitySelector() sdkmetric.TemporalitySelector {
+	if o.config.MetricTemporality == TemporalityDelta {
+		return deltaTemporality
+	}
+	return sdkmetric.DefaultTemporalitySelector
+}
+
+// deltaTemporality reports deltas for counters and histograms, and keeps
+// up-down counters cumulative since their deltas can't be summed back into
+// a current value by most backends
+func deltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// OTLP transports selectable with Config.Protocol
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http"
+)
+
+// RetryConfig is the retry policy of the OTLP exporters for failed exports
+type RetryConfig struct {
+	Enabled         bool
+	InitialInterval time.Duration // Wait after the first failure
+	MaxInterval     time.Duration // Upper bound of the exponential backoff
+	MaxElapsedTime  time.Duration // Total time before the batch is dropped
+}
+
+// defaultRetry is used when Config.Retry is nil
+var defaultRetry = RetryConfig{
+	Enabled:         true,
+	InitialInterval: 1 * time.Second,
+	MaxInterval:     10 * time.Second,
+	MaxElapsedTime:  30 * time.Second,
+}
+
+// retry returns the retry policy shared by the exporters
+func (o *Otel) retry() RetryConfig {
+	if o.config.Retry != nil {
+		return *o.config.Retry
+	}
+	return defaultRetry
+}
+
+// useHTTP reports whether the OTLP exporters use HTTP instead of gRPC
+func (o *Otel) useHTTP() bool {
+	return o.config.Protocol == ProtocolHTTP
+}
+
+// httpHeaders returns the export headers for the HTTP exporters, which carry
+// the user agent as a header since they have no dial options
+func (o *Otel) httpHeaders() map[string]string {
+	headers := o.commonHeaders()
+	if o.config.UserAgent != "" {
+		headers["User-Agent"] = o.config.UserAgent
+	}
+	return headers
+}
+
+// stdout reports whether telemetry is printed instead of sent to a collector
+func (o *Otel) stdout() bool {
+	return o.config.Exporter == ExporterStdout
+}
+
+// stdoutWriter returns where the stdout exporters write
+func (o *Otel) stdoutWriter() io.Writer {
+	return cmp.Or[io.Writer](o.config.StdoutWriter, os.Stdout)
+}
+
+// unixSocket returns the socket path when Host is a unix:// address
+func (o *Otel) unixSocket() (string, bool) {
+	return strings.CutPrefix(o.config.Host, "unix://")
+}
+
+// grpcDialOptions returns the dial options shared by the gRPC exporters
+func (o *Otel) grpcDialOptions() []grpc.DialOption {
+	var opts []grpc.DialOption
+	if o.config.UserAgent != "" {
+		opts = append(opts, grpc.WithUserAgent(o.config.UserAgent))
+	}
+	if path, ok := o.unixSocket(); ok {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}))
+	}
+	return opts
+}
+
+// Ping checks that the collector at Host accepts connections, e.g. for a
+// readiness probe. It gives up after the export timeout unless ctx expires
+// first, and always succeeds with the stdout exporter or when disabled.
+func (o *Otel) Ping(ctx context.Context) error {
+	c := o.snapshot()
+	if c.stdout() || c.config.Disabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+
+	network, address := "tcp", c.config.Host
+	if path, ok := c.unixSocket(); ok {
+		network, address = "unix", path
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return fmt.Errorf("otel: collector %s is unreachable: %w", c.config.Host, err)
+	}
+	return conn.Close()
+}
+
+// exporterInitError wraps err from creating the exporter of signal in an
+// ExporterInitError naming the configured endpoint
+func (o *Otel) exporterInitError(signal string, err error) error {
+	endpoint := o.config.Host
+	if o.stdout() {
+		endpoint = ExporterStdout
+	}
+	return &ExporterInitError{Signal: signal, Endpoint: endpoint, Err: err}
+}
+
+// newLogExporter creates the log exporter selected by the configuration
+func (o *Otel) newLogExporter(ctx context.Context) (sdklog.Exporter, error) {
+	if o.stdout() {
+		return stdoutlog.New(stdoutlog.WithWriter(o.stdoutWriter()), stdoutlog.WithPrettyPrint())
+	}
+	if o.useHTTP() {
+		opts := []otlploghttp.Option{
+			otlploghttp.WithEndpoint(o.config.Host),
+			otlploghttp.WithInsecure(),
+			otlploghttp.WithHeaders(o.httpHeaders()),
+			otlploghttp.WithRetry(otlploghttp.RetryConfig(o.retry())),
+		}
+		if o.config.Compression == "gzip" {
+			opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+		}
+		if o.config.LogPath != "" {
+			opts = append(opts, otlploghttp.WithURLPath(o.config.LogPath))
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+
+	opts := []otlploggrpc.Option{
+		otlploggrpc.WithEndpoint(o.config.Host),
+		otlploggrpc.WithInsecure(),
+		otlploggrpc.WithHeaders(o.commonHeaders()),
+		otlploggrpc.WithRetry(otlploggrpc.RetryConfig(o.retry())),
+	}
+	if o.config.Compression == "gzip" {
+		opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+	}
+	if dialOpts := o.grpcDialOptions(); len(dialOpts) > 0 {
+		opts = append(opts, otlploggrpc.WithDialOption(dialOpts...))
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
+
+// newMetricExporter creates the metric exporter selected by the configuration
+func (o *Otel) newMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	if o.stdout() {
+		return stdoutmetric.New(
+			stdoutmetric.WithWriter(o.stdoutWriter()),
+			stdoutmetric.WithPrettyPrint(),
+			stdoutmetric.WithTemporalitySelector(o.temporalitySelector()),
+		)
+	}
+	if o.useHTTP() {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(o.config.Host),
+			otlpmetrichttp.WithInsecure(),
+			otlpmetrichttp.WithHeaders(o.httpHeaders()),
+			otlpmetrichttp.WithTimeout(exportTimeout),
+			otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig(o.retry())),
+			otlpmetrichttp.WithTemporalitySelector(o.temporalitySelector()),
+		}
+		if o.config.Compression == "gzip" {
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		}
+		if o.config.MetricPath != "" {
+			opts = append(opts, otlpmetrichttp.WithURLPath(o.config.MetricPath))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(o.config.Host),
+		otlpmetricgrpc.WithInsecure(),
+		otlpmetricgrpc.WithHeaders(o.commonHeaders()),
+		otlpmetricgrpc.WithTimeout(exportTimeout),
+		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig(o.retry())),
+		otlpmetricgrpc.WithTemporalitySelector(o.temporalitySelector()),
+	}
+	if o.config.Compression == "gzip" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
+	}
+	if dialOpts := o.grpcDialOptions(); len(dialOpts) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithDialOption(dialOpts...))
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+// newTraceExporter creates the span exporter selected by the configuration
+func (o *Otel) newTraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	if o.stdout() {
+		return stdouttrace.New(stdouttrace.WithWriter(o.stdoutWriter()), stdouttrace.WithPrettyPrint())
+	}
+	if o.useHTTP() {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(o.config.Host),
+			otlptracehttp.WithInsecure(),
+			otlptracehttp.WithHeaders(o.httpHeaders()),
+			otlptracehttp.WithTimeout(exportTimeout),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig(o.retry())),
+		}
+		if o.config.Compression == "gzip" {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
+		if o.config.TracePath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(o.config.TracePath))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(o.config.Host),
+		otlptracegrpc.WithInsecure(),
+		otlptracegrpc.WithHeaders(o.commonHeaders()),
+		otlptracegrpc.WithTimeout(exportTimeout),
+		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig(o.retry())),
+	}
+	if o.config.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	if dialOpts := o.grpcDialOptions(); len(dialOpts) > 0 {
+		opts = append(opts, otlptracegrpc.WithDialOption(dialOpts...))
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
@@ -0,0 +1,95 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// traceCollector is an OTLP trace service counting the spans it receives
+type traceCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	spans atomic.Int64
+}
+
+func (c *traceCollector) Export(_ context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			c.spans.Add(int64(len(ss.GetSpans())))
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// listenUnix serves a trace collector on a unix socket and returns its unix:// host
+func listenUnix(t *testing.T) (string, *traceCollector) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "otlp.sock")
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	collector := &traceCollector{}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, collector)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+	return "unix://" + path, collector
+}
+
+func TestGRPCDialOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		wantPath  string
+		wantUnix  bool
+		wantCount int
+	}{
+		{"tcp host", Config{Host: "localhost:4317"}, "", false, 0},
+		{"unix host", Config{Host: "unix:///run/otel/otlp.sock"}, "/run/otel/otlp.sock", true, 1},
+		{"unix host with user agent", Config{Host: "unix:///run/otel/otlp.sock", UserAgent: "test"}, "/run/otel/otlp.sock", true, 2},
+		{"tcp host with user agent", Config{Host: "localhost:4317", UserAgent: "test"}, "", false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &Otel{config: tt.config}
+			path, ok := o.unixSocket()
+			if ok != tt.wantUnix || (ok && path != tt.wantPath) {
+				t.Errorf("unixSocket() = %q, %v, want %q, %v", path, ok, tt.wantPath, tt.wantUnix)
+			}
+			if got := len(o.grpcDialOptions()); got != tt.wantCount {
+				t.Errorf("got %d dial options, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestUnixSocketExport(t *testing.T) {
+	ctx := context.Background()
+	host, collector := listenUnix(t)
+	o := New(Config{Host: host, Protocol: ProtocolGRPC})
+
+	if err := o.Ping(ctx); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+
+	exporter, err := o.newTraceExporter(ctx)
+	if err != nil {
+		t.Fatalf("newTraceExporter: %v", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(ctx)
+
+	_, span := provider.Tracer("test").Start(ctx, "over unix")
+	span.End()
+
+	if got := collector.spans.Load(); got != 1 {
+		t.Errorf("collector received %d spans, want 1", got)
+	}
+}
@@ -0,0 +1,121 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+	"go.opentelemetry.io/otel/log/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// setGlobal installs a provider as the global one for signal. current is the
+// global provider in place and own the provider this instance installed
+// before, if any. A provider installed by another library is only replaced
+// when Config.OverwriteGlobals is set.
+func (o *Otel) setGlobal(signal string, current, own any, set func()) {
+	if isDefaultProvider(current) || (!isNil(own) && current == own) {
+		set()
+		return
+	}
+
+	if o.config.OverwriteGlobals {
+		otel.Handle(fmt.Errorf("otel: overwriting global %s provider %T installed by another library", signal, current))
+		set()
+		return
+	}
+	otel.Handle(fmt.Errorf("otel: keeping global %s provider %T installed by another library; set OverwriteGlobals to replace it", signal, current))
+}
+
+// isDefaultProvider reports whether p is the placeholder the OTEL API returns
+// before any provider is installed, or a no-op provider
+func isDefaultProvider(p any) bool {
+	return isGlobalDelegate(p) || strings.HasSuffix(providerPkgPath(p), "/noop")
+}
+
+// isGlobalDelegate reports whether p is the delegating placeholder the OTEL API
+// returns before any provider is installed
+func isGlobalDelegate(p any) bool {
+	return strings.HasSuffix(providerPkgPath(p), "/internal/global")
+}
+
+// providerPkgPath returns the package path of the provider's type
+func providerPkgPath(p any) string {
+	t := reflect.TypeOf(p)
+	if t == nil {
+		return ""
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath()
+}
+
+// isNil reports whether v is nil or a nil pointer
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Pointer && rv.IsNil()
+}
+
+// reloadableLoggerProvider is the logger provider an Otel instance installs as
+// the global one. Its loggers emit through the instance's current provider, so
+// slog handlers created before Reconfigure keep working after it instead of
+// writing to the provider that was shut down.
+type reloadableLoggerProvider struct {
+	embedded.LoggerProvider
+	otel *Otel
+}
+
+// Logger returns a logger following the instance's current provider
+func (p *reloadableLoggerProvider) Logger(name string, opts ...log.LoggerOption) log.Logger {
+	return &reloadableLogger{otel: p.otel, name: name, opts: opts}
+}
+
+// reloadableLogger forwards to the logger of the same name on the current
+// provider, looking it up again whenever the provider changes
+type reloadableLogger struct {
+	embedded.Logger
+	otel  *Otel
+	name  string
+	opts  []log.LoggerOption
+	bound atomic.Pointer[boundLogger]
+}
+
+// boundLogger is a logger together with the provider it was obtained from
+type boundLogger struct {
+	provider *sdklog.LoggerProvider
+	logger   log.Logger
+}
+
+// logger returns the logger of the current provider, or a no-op logger when
+// there is none
+func (l *reloadableLogger) logger() log.Logger {
+	provider := l.otel.sdkLoggerProvider()
+	if b := l.bound.Load(); b != nil && b.provider == provider {
+		return b.logger
+	}
+	var logger log.Logger = noop.NewLoggerProvider().Logger(l.name)
+	if provider != nil {
+		logger = provider.Logger(l.name, l.opts...)
+	}
+	l.bound.Store(&boundLogger{provider: provider, logger: logger})
+	return logger
+}
+
+// Emit emits the record through the current provider
+func (l *reloadableLogger) Emit(ctx context.Context, record log.Record) {
+	l.logger().Emit(ctx, record)
+}
+
+// Enabled reports whether the current provider processes the record
+func (l *reloadableLogger) Enabled(ctx context.Context, param log.EnabledParameters) bool {
+	return l.logger().Enabled(ctx, param)
+}
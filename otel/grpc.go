@@ -0,0 +1,146 @@
+package otel
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns an interceptor that wraps each unary call in a
+// server span continuing the trace context from the incoming metadata. When
+// metrics is non-nil it also records the call latency, the accepted or failed
+// call and the calls in flight, like HTTPMiddleware; calls ending with a
+// server-side status code (Unknown, DeadlineExceeded, Unimplemented, Internal,
+// Unavailable, DataLoss) count as failed.
+func (o *Otel) UnaryServerInterceptor(metrics *MetricsRecorder) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var resp any
+		err := o.serveRPC(ctx, metrics, info.FullMethod, func(ctx context.Context) error {
+			var err error
+			resp, err = handler(ctx, req)
+			return err
+		})
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns the streaming counterpart of UnaryServerInterceptor
+func (o *Otel) StreamServerInterceptor(metrics *MetricsRecorder) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return o.serveRPC(ss.Context(), metrics, info.FullMethod, func(ctx context.Context) error {
+			return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		})
+	}
+}
+
+// serveRPC runs call in a server span and records its metrics when metrics is non-nil
+func (o *Otel) serveRPC(ctx context.Context, metrics *MetricsRecorder, fullMethod string, call func(context.Context) error) error {
+	ctx, span := o.startRPCSpan(ctx, fullMethod)
+	defer span.End()
+
+	if metrics != nil {
+		metrics.RecordInFlight(ctx, 1, semconv.RPCSystemGRPC)
+		defer metrics.RecordInFlight(ctx, -1, semconv.RPCSystemGRPC)
+	}
+
+	start := time.Now()
+	err := call(ctx)
+	code := recordRPCStatus(span, err)
+
+	if metrics == nil {
+		return err
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("endpoint", strings.TrimPrefix(fullMethod, "/")),
+		semconv.RPCGRPCStatusCodeKey.Int(int(code)),
+	}
+	metrics.RecordLatency(ctx, time.Since(start), attrs...)
+	if rpcFailed(code) {
+		metrics.RecordFailedRequest(ctx, append(attrs, ErrorClassKey.String(ErrorClassServer))...)
+	} else {
+		metrics.RecordAcceptedRequest(ctx, attrs...)
+	}
+	return err
+}
+
+// startRPCSpan extracts the incoming trace context and starts a server span named after the method
+func (o *Otel) startRPCSpan(ctx context.Context, fullMethod string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = o.Propagator().Extract(ctx, metadataCarrier(md))
+
+	attrs := []attribute.KeyValue{semconv.RPCSystemGRPC}
+	if service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/"); ok {
+		attrs = append(attrs, semconv.RPCService(service), semconv.RPCMethod(method))
+	}
+
+	return o.StartSpan(ctx, strings.TrimPrefix(fullMethod, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// recordRPCStatus sets the gRPC status code on the span, marks server-side
+// failures as errors and returns the code
+func recordRPCStatus(span trace.Span, err error) codes.Code {
+	s := status.Convert(err)
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(s.Code())))
+	if rpcFailed(s.Code()) {
+		span.SetStatus(otelcodes.Error, s.Message())
+	}
+	return s.Code()
+}
+
+// rpcFailed reports whether the status code is a server-side failure
+func rpcFailed(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// metadataCarrier adapts gRPC metadata to propagation.TextMapCarrier
+type metadataCarrier metadata.MD
+
+// Get returns the first value for the key
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Set stores the value under the key
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys lists the keys stored in the carrier
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// serverStream overrides the stream context with the one carrying the span
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the span context
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
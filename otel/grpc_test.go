@@ -0,0 +1,58 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryServerInterceptorMetrics(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx)
+	recorder, err := NewMetricsRecorder(provider, "test")
+	if err != nil {
+		t.Fatalf("NewMetricsRecorder: %v", err)
+	}
+
+	interceptor := New(Config{Exporter: ExporterStdout}).UnaryServerInterceptor(recorder)
+	info := &grpc.UnaryServerInfo{FullMethod: "/shop.Cart/Add"}
+	for _, code := range []codes.Code{codes.OK, codes.NotFound, codes.Internal, codes.Unavailable} {
+		resp, err := interceptor(ctx, "req", info, func(context.Context, any) (any, error) {
+			if code != codes.OK {
+				return nil, status.Error(code, "failed")
+			}
+			return "resp", nil
+		})
+		if status.Code(err) != code {
+			t.Errorf("code = %v, want %v", status.Code(err), code)
+		}
+		if code == codes.OK && resp != "resp" {
+			t.Errorf("resp = %v, want %q", resp, "resp")
+		}
+	}
+
+	if got := sumByAttribute(t, reader, "test_module_requests_accepted_total", "endpoint"); got["shop.Cart/Add"] != 2 {
+		t.Errorf("accepted = %v, want 2 for shop.Cart/Add", got)
+	}
+	if got := sumByAttribute(t, reader, "test_module_requests_failed_total", "endpoint"); got["shop.Cart/Add"] != 2 {
+		t.Errorf("failed = %v, want 2 for shop.Cart/Add", got)
+	}
+	if got, ok := sumByAttribute(t, reader, "test_module_requests_in_flight", "rpc.system")["grpc"]; !ok || got != 0 {
+		t.Errorf("in flight = %v, want 0 once the calls return", got)
+	}
+}
+
+func TestStreamServerInterceptorWithoutMetrics(t *testing.T) {
+	interceptor := New(Config{Exporter: ExporterStdout}).StreamServerInterceptor(nil)
+	err := interceptor(nil, &serverStream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/shop.Cart/Watch"},
+		func(any, grpc.ServerStream) error { return status.Error(codes.Internal, "failed") })
+	if status.Code(err) != codes.Internal {
+		t.Errorf("code = %v, want %v", status.Code(err), codes.Internal)
+	}
+}
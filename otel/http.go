@@ -0,0 +1,243 @@
+package otel
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// unmatchedRoute is the endpoint metric attribute of requests that matched no
+// ServeMux pattern, so unknown paths don't each create a new series
+const unmatchedRoute = "unmatched"
+
+// MiddlewareOption configures HTTPMiddleware
+type MiddlewareOption func(*middlewareConfig)
+
+// middlewareConfig holds the HTTPMiddleware settings
+type middlewareConfig struct {
+	skipPaths map[string]struct{}
+}
+
+// WithSkipPaths excludes requests whose URL path exactly matches one of paths
+// (e.g. "/healthz", "/metrics"); they are served without creating a span or
+// recording metrics
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		for _, p := range paths {
+			c.skipPaths[p] = struct{}{}
+		}
+	}
+}
+
+// HTTPMiddleware wraps handlers with a server span and request metrics. The
+// span continues the trace context found in the request headers and is named
+// after the matched route when the wrapped handler is an http.ServeMux.
+// Responses with a 5xx status count as failed requests, everything else as
+// accepted; request sizes (when Content-Length is known) and response sizes are
+// recorded too. Metrics carry the matched route as the endpoint attribute, or
+// "unmatched" when no ServeMux pattern matched. metrics may be nil to only
+// record spans.
+func (o *Otel) HTTPMiddleware(metrics *MetricsRecorder, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := middlewareConfig{skipPaths: make(map[string]struct{})}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, skip := cfg.skipPaths[r.URL.Path]; skip {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := o.Propagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := o.StartSpan(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethod(r.Method),
+					semconv.HTTPTarget(r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			if metrics != nil {
+				method := semconv.HTTPMethod(r.Method)
+				metrics.RecordInFlight(ctx, 1, method)
+				defer metrics.RecordInFlight(ctx, -1, method)
+			}
+
+			start := time.Now()
+			rw := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			r = r.WithContext(ctx)
+			next.ServeHTTP(rw.wrap(), r)
+
+			// ServeMux sets the pattern on the request it was given while routing;
+			// the raw path stays on the span only, as http.target
+			route := r.Pattern
+			if route != "" {
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(semconv.HTTPRoute(route))
+			} else {
+				route = unmatchedRoute
+			}
+			span.SetAttributes(semconv.HTTPStatusCode(rw.status))
+			if rw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rw.status))
+			}
+
+			if metrics == nil {
+				return
+			}
+			attrs := []attribute.KeyValue{
+				attribute.String("endpoint", route),
+				semconv.HTTPMethod(r.Method),
+				semconv.HTTPStatusCode(rw.status),
+			}
+			metrics.RecordLatency(ctx, time.Since(start), attrs...)
+			if r.ContentLength >= 0 {
+				metrics.RecordRequestSize(ctx, r.ContentLength, attrs...)
+			}
+			metrics.RecordResponseSize(ctx, rw.written, attrs...)
+			if rw.status >= http.StatusInternalServerError {
+				metrics.RecordFailedRequest(ctx, append(attrs, ErrorClassKey.String(ErrorClassServer))...)
+			} else {
+				metrics.RecordAcceptedRequest(ctx, attrs...)
+			}
+		})
+	}
+}
+
+// HTTPTransport wraps base (http.DefaultTransport when nil) so each outbound
+// request gets a client span and carries the trace context in its headers,
+// using the propagator installed by Setup. The span ends once the response
+// headers arrive; responses with a 4xx or 5xx status and transport errors mark
+// it as failed.
+func (o *Otel) HTTPTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, otel: o}
+}
+
+// transport is the http.RoundTripper returned by HTTPTransport
+type transport struct {
+	base http.RoundTripper
+	otel *Otel
+}
+
+// RoundTrip sends the request inside a client span
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx, span := t.otel.StartSpan(r.Context(), r.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPMethod(r.Method),
+			semconv.HTTPURL(r.URL.Redacted()),
+			semconv.NetPeerName(r.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	// a RoundTripper must not modify the caller's request
+	r = r.Clone(ctx)
+	t.otel.Propagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		RecordTraceError(err, t.otel.currentConfig().ServiceName, span)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// responseRecorder captures the status code and body size written by a handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	written     int64
+}
+
+// WriteHeader records the status code before writing it
+func (rw *responseRecorder) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+// Write marks the header as written with the implicit 200 status and counts
+// the bytes written
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+	return n, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// wrap returns rw as a writer that also implements http.Flusher and
+// http.Hijacker when the underlying writer does, so streaming responses and
+// protocol upgrades keep working behind the middleware. Type assertions on the
+// result then answer like they would on the original writer.
+func (rw *responseRecorder) wrap() http.ResponseWriter {
+	_, canFlush := rw.ResponseWriter.(http.Flusher)
+	_, canHijack := rw.ResponseWriter.(http.Hijacker)
+	switch {
+	case canFlush && canHijack:
+		return struct {
+			*responseRecorder
+			recorderFlusher
+			recorderHijacker
+		}{rw, recorderFlusher{rw}, recorderHijacker{rw}}
+	case canFlush:
+		return struct {
+			*responseRecorder
+			recorderFlusher
+		}{rw, recorderFlusher{rw}}
+	case canHijack:
+		return struct {
+			*responseRecorder
+			recorderHijacker
+		}{rw, recorderHijacker{rw}}
+	}
+	return rw
+}
+
+// recorderFlusher forwards Flush to the writer of a responseRecorder
+type recorderFlusher struct {
+	rw *responseRecorder
+}
+
+// Flush sends buffered data, which writes the header with the implicit 200
+// status if the handler hasn't written it yet
+func (f recorderFlusher) Flush() {
+	f.rw.wroteHeader = true
+	f.rw.ResponseWriter.(http.Flusher).Flush()
+}
+
+// recorderHijacker forwards Hijack to the writer of a responseRecorder
+type recorderHijacker struct {
+	rw *responseRecorder
+}
+
+// Hijack hands the connection over to the handler, e.g. for a websocket upgrade
+func (h recorderHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.rw.wroteHeader = true
+	return h.rw.ResponseWriter.(http.Hijacker).Hijack()
+}
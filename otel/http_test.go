@@ -0,0 +1,142 @@
+package otel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// plainWriter is a ResponseWriter that supports neither flushing nor hijacking
+type plainWriter struct {
+	header http.Header
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *plainWriter) WriteHeader(int)             {}
+
+func TestHTTPMiddlewareFlush(t *testing.T) {
+	o := New(Config{Exporter: ExporterStdout})
+	handler := o.HTTPMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("writer behind the middleware does not implement http.Flusher")
+		}
+		io.WriteString(w, "event: ping\n\n")
+		flusher.Flush()
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+
+	if !rec.Flushed {
+		t.Error("Flush was not forwarded to the underlying writer")
+	}
+	if got := rec.Body.String(); got != "event: ping\n\n" {
+		t.Errorf("body = %q, want %q", got, "event: ping\n\n")
+	}
+}
+
+func TestHTTPMiddlewareHijack(t *testing.T) {
+	o := New(Config{Exporter: ExporterStdout})
+	server := httptest.NewServer(o.HTTPMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("writer behind the middleware does not implement http.Hijacker")
+			return
+		}
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	})))
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+}
+
+func TestHTTPMiddlewareKeepsWriterInterfaces(t *testing.T) {
+	o := New(Config{Exporter: ExporterStdout})
+	handler := o.HTTPMiddleware(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Error("writer implements http.Flusher although the underlying one does not")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("writer implements http.Hijacker although the underlying one does not")
+		}
+	}))
+	handler.ServeHTTP(&plainWriter{header: http.Header{}}, httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+// sumByAttribute collects the int64 sum named name from reader and totals its
+// data points by the value of the attribute key
+func sumByAttribute(t *testing.T, reader sdkmetric.Reader, name string, key attribute.Key) map[string]int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	totals := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok || m.Name != name {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				value, _ := dp.Attributes.Value(key)
+				totals[value.Emit()] += dp.Value
+			}
+		}
+	}
+	return totals
+}
+
+func TestHTTPMiddlewareUnmatchedEndpoint(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+	recorder, err := NewMetricsRecorder(provider, "test")
+	if err != nil {
+		t.Fatalf("NewMetricsRecorder: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(http.ResponseWriter, *http.Request) {})
+	o := New(Config{Exporter: ExporterStdout})
+	handler := o.HTTPMiddleware(recorder)(mux)
+	for _, path := range []string{"/items/1", "/items/2", "/wp-admin", "/.env", "/random/probe"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	got := sumByAttribute(t, reader, "test_module_requests_accepted_total", "endpoint")
+	want := map[string]int64{"GET /items/{id}": 2, unmatchedRoute: 3}
+	if len(got) != len(want) {
+		t.Errorf("endpoints = %v, want %v", got, want)
+	}
+	for endpoint, n := range want {
+		if got[endpoint] != n {
+			t.Errorf("endpoint %q counted %d requests, want %d", endpoint, got[endpoint], n)
+		}
+	}
+}
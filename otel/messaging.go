@@ -3,23 +3,73 @@ package otel
 import (
 	"context"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // MessagingPropagator handles trace context propagation for messaging systems
 type MessagingPropagator struct {
 	propagator propagation.TextMapPropagator
+	scopeName  string
+	system     string
+}
+
+// MessagingOption configures a MessagingPropagator
+type MessagingOption func(*MessagingPropagator)
+
+// WithMessagingScope sets the instrumentation scope of consumer and producer spans
+func WithMessagingScope(name string) MessagingOption {
+	return func(mp *MessagingPropagator) {
+		mp.scopeName = name
+	}
+}
+
+// WithMessagingSystem sets messaging.system (e.g. "kafka", "rabbitmq") on every
+// consumer and producer span
+func WithMessagingSystem(system string) MessagingOption {
+	return func(mp *MessagingPropagator) {
+		mp.system = system
+	}
+}
+
+// WithPropagators selects the propagation formats by name, as in
+// Config.Propagators. Unknown names are reported to the global error handler
+// and the default formats are kept.
+func WithPropagators(names ...string) MessagingOption {
+	return func(mp *MessagingPropagator) {
+		propagator, err := newPropagator(names)
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		mp.propagator = propagator
+	}
+}
+
+// WithOtelPropagator shares the propagator of an Otel instance, so messages
+// use the same formats as the rest of the service without relying on globals
+func WithOtelPropagator(o *Otel) MessagingOption {
+	return func(mp *MessagingPropagator) {
+		mp.propagator = o.Propagator()
+	}
 }
 
 // NewMessagingPropagator creates a new messaging propagator
-func NewMessagingPropagator() *MessagingPropagator {
-	return &MessagingPropagator{
+func NewMessagingPropagator(opts ...MessagingOption) *MessagingPropagator {
+	mp := &MessagingPropagator{
 		propagator: propagation.NewCompositeTextMapPropagator(
 			propagation.TraceContext{},
 			propagation.Baggage{},
 		),
+		scopeName: DefaultScopeName,
+	}
+	for _, opt := range opts {
+		opt(mp)
 	}
+	return mp
 }
 
 // InjectTraceContext injects trace context into a carrier
@@ -27,19 +77,64 @@ func (mp *MessagingPropagator) InjectTraceContext(ctx context.Context, carrier p
 	mp.propagator.Inject(ctx, carrier)
 }
 
+// InjectToMap injects trace context into a new map, e.g. to copy into the
+// headers of an outbound request
+func (mp *MessagingPropagator) InjectToMap(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	mp.propagator.Inject(ctx, carrier)
+	return carrier
+}
+
 // ExtractTraceContext extracts trace context from a carrier
 func (mp *MessagingPropagator) ExtractTraceContext(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
 	return mp.propagator.Extract(ctx, carrier)
 }
 
-// StartConsumerSpan starts a span for a message consumer
-func (mp *MessagingPropagator) StartConsumerSpan(ctx context.Context, tracerProvider trace.TracerProvider, operationName string) (context.Context, trace.Span) {
-	tracer := tracerProvider.Tracer("otel-client")
-	return tracer.Start(ctx, operationName, trace.WithSpanKind(trace.SpanKindConsumer))
+// LinkFromCarrier builds a link to the producer span whose context the carrier
+// holds, for a consumer span processing several messages at once
+func (mp *MessagingPropagator) LinkFromCarrier(carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) trace.Link {
+	ctx := mp.propagator.Extract(context.Background(), carrier)
+	return trace.Link{SpanContext: trace.SpanContextFromContext(ctx), Attributes: attrs}
+}
+
+// StartConsumerSpan starts a span for a message consumer with messaging.operation
+// set to "process"; opts are applied after the span kind and default attributes,
+// so MessagingAttributes can override them
+func (mp *MessagingPropagator) StartConsumerSpan(ctx context.Context, tracerProvider trace.TracerProvider, operationName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return mp.startSpan(ctx, tracerProvider, operationName, trace.SpanKindConsumer, semconv.MessagingOperationProcess, opts)
+}
+
+// StartProducerSpan starts a span for a message producer with messaging.operation
+// set to "publish"; opts are applied after the span kind and default attributes,
+// so MessagingAttributes can override them
+func (mp *MessagingPropagator) StartProducerSpan(ctx context.Context, tracerProvider trace.TracerProvider, operationName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return mp.startSpan(ctx, tracerProvider, operationName, trace.SpanKindProducer, semconv.MessagingOperationPublish, opts)
 }
 
-// StartProducerSpan starts a span for a message producer
-func (mp *MessagingPropagator) StartProducerSpan(ctx context.Context, tracerProvider trace.TracerProvider, operationName string) (context.Context, trace.Span) {
-	tracer := tracerProvider.Tracer("otel-client")
-	return tracer.Start(ctx, operationName, trace.WithSpanKind(trace.SpanKindProducer))
+// startSpan starts a messaging span of the given kind and default operation
+func (mp *MessagingPropagator) startSpan(ctx context.Context, tracerProvider trace.TracerProvider, operationName string, kind trace.SpanKind, operation attribute.KeyValue, opts []trace.SpanStartOption) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{operation}
+	if mp.system != "" {
+		attrs = append(attrs, semconv.MessagingSystem(mp.system))
+	}
+	opts = append([]trace.SpanStartOption{trace.WithSpanKind(kind), trace.WithAttributes(attrs...)}, opts...)
+	return tracerProvider.Tracer(mp.scopeName).Start(ctx, operationName, opts...)
+}
+
+// MessagingAttributes returns a span option setting messaging.system (e.g.
+// "kafka"), messaging.destination.name (the topic or queue) and
+// messaging.operation ("publish", "receive" or "process"). Empty values are
+// left out.
+func MessagingAttributes(system, destination, operation string) trace.SpanStartOption {
+	var attrs []attribute.KeyValue
+	if system != "" {
+		attrs = append(attrs, semconv.MessagingSystem(system))
+	}
+	if destination != "" {
+		attrs = append(attrs, semconv.MessagingDestinationName(destination))
+	}
+	if operation != "" {
+		attrs = append(attrs, semconv.MessagingOperationKey.String(operation))
+	}
+	return trace.WithAttributes(attrs...)
 }
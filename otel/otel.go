@@ -1,33 +1,44 @@
 package otel
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/attribute"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Config holds configuration parameters for Otel initialization
-type Config struct {
-	Host         string
-	Token        string
-	ServiceName  string
-	Environment  string
-	Organization string
-	StreamName   string
-	SampleRate   float64 // Sampling rate for traces (0 to 1; 0 disables sampling)
-}
+// Export settings shared by the providers
+const (
+	exportTimeout     = 5 * time.Second
+	logExportInterval = 1 * time.Second
+	logQueueSize      = 2048
+	metricInterval    = 10 * time.Second
+
+	defaultShutdownTimeout = 10 * time.Second
+)
 
 // Otel encapsulates OpenTelemetry providers
 type Otel struct {
@@ -35,110 +46,636 @@ type Otel struct {
 	logger *sdklog.LoggerProvider
 	meter  *sdkmetric.MeterProvider
 	tracer *sdktrace.TracerProvider
+
+	// logs follows logger across Reconfigure and is installed as the global
+	// logger provider in its place
+	logs *reloadableLoggerProvider
+
+	metricsStart time.Time
+	logLevel     *slog.LevelVar
+	promRegistry *prometheus.Registry
+	propagator   propagation.TextMapPropagator
+
+	mu            sync.Mutex
+	recorders     map[string]*MetricsRecorder
+	heartbeatStop chan struct{}
+
+	tracers sync.Map // scope name -> trace.Tracer
+
+	// stateMu guards the configuration and providers against Reconfigure
+	stateMu sync.RWMutex
 }
 
-// New creates and initializes a new Otel instance with the provided configuration
+// New creates and initializes a new Otel instance with the provided
+// configuration. Empty Host and ServiceName fields fall back to the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_SERVICE_NAME environment variables, and
+// OTEL_EXPORTER_OTLP_HEADERS adds headers not set through the configuration.
+// OTEL_SDK_DISABLED=true sets Disabled.
 func New(config Config) *Otel {
-	return &Otel{
-		config: config,
+	o := &Otel{
+		config:    config.withEnv(),
+		logLevel:  new(slog.LevelVar),
+		recorders: make(map[string]*MetricsRecorder),
 	}
+	o.logs = &reloadableLoggerProvider{otel: o}
+	o.logLevel.Set(config.LogLevel)
+	return o
 }
 
-// Setup initializes all OpenTelemetry providers
+// Setup initializes all OpenTelemetry providers. With Config.BestEffort a
+// provider that fails to initialize is skipped, its getter returns nil, and the
+// joined errors are returned once the others are set up. Without it, a failure
+// shuts down the providers created so far and leaves the globals untouched.
 func (o *Otel) Setup(ctx context.Context) error {
-	// Initialize logger provider
-	logger, err := o.initLoggerProvider(ctx)
-	if err != nil {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("otel: Setup called with a context that is already done: %w", err)
+	}
+	next, err := o.build(ctx, o.currentConfig())
+	if next == nil {
 		return err
 	}
-	o.logger = logger
-	global.SetLoggerProvider(logger)
+
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	o.install(next)
+	return err
+}
+
+// build creates the providers for cfg without installing them, returning them
+// in a new instance. With Config.BestEffort a provider that fails is left nil
+// and its error is returned along with the instance; otherwise the providers
+// created so far are shut down and only the error is returned.
+func (o *Otel) build(ctx context.Context, cfg Config) (*Otel, error) {
+	next := &Otel{config: cfg}
+	if cfg.Disabled {
+		next.buildDisabled()
+		return next, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	// with BestEffort a failed provider is skipped and its error reported at the end
+	var errs []error
+	failed := func(signal string, err error) error {
+		if !cfg.BestEffort {
+			return errors.Join(err, next.shutdownProviders(ctx))
+		}
+		errs = append(errs, fmt.Errorf("otel: %s provider: %w", signal, err))
+		return nil
+	}
+
+	// Initialize logger provider
+	if logger, err := next.initLoggerProvider(ctx); err != nil {
+		if err := failed("logger", err); err != nil {
+			return nil, err
+		}
+	} else {
+		next.logger = logger
+	}
 
 	// Initialize meter provider
-	meter, err := o.initMeterProvider(ctx)
-	if err != nil {
-		return err
+	if meter, err := next.initMeterProvider(ctx); err != nil {
+		if err := failed("meter", err); err != nil {
+			return nil, err
+		}
+	} else {
+		next.meter = meter
+		next.metricsStart = time.Now()
 	}
-	o.meter = meter
-	otel.SetMeterProvider(meter)
 
 	// Initialize tracer provider
-	tracer, err := o.initTracerProvider(ctx)
+	if tracer, err := next.initTracerProvider(ctx); err != nil {
+		if err := failed("tracer", err); err != nil {
+			return nil, err
+		}
+	} else {
+		next.tracer = tracer
+	}
+
+	propagator, err := newPropagator(cfg.Propagators)
 	if err != nil {
-		return err
+		return nil, errors.Join(err, next.shutdownProviders(ctx))
 	}
-	o.tracer = tracer
-	otel.SetTracerProvider(tracer)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
+	next.propagator = propagator
 
-	return nil
+	return next, errors.Join(errs...)
+}
+
+// buildDisabled creates providers without exporters, so instrumentation keeps
+// working against them but records and sends nothing
+func (o *Otel) buildDisabled() {
+	o.logger = sdklog.NewLoggerProvider()
+	o.meter = sdkmetric.NewMeterProvider()
+	o.metricsStart = time.Now()
+	o.tracer = sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
 }
 
-// Shutdown gracefully shuts down all providers
+// install makes the providers built in next the current ones and installs
+// them as globals in place of the ones o installed before, returning the
+// replaced providers. A provider that failed with BestEffort leaves its global
+// as it was. The caller holds stateMu.
+func (o *Otel) install(next *Otel) *Otel {
+	old := &Otel{config: o.config, logger: o.logger, meter: o.meter, tracer: o.tracer}
+	o.config = next.config
+
+	if next.logger != nil {
+		o.setGlobal("logger", global.GetLoggerProvider(), o.logs, func() { global.SetLoggerProvider(o.logs) })
+	}
+	if next.meter != nil {
+		o.setGlobal("meter", otel.GetMeterProvider(), old.meter, func() { otel.SetMeterProvider(next.meter) })
+	}
+	if next.tracer != nil {
+		o.setGlobal("tracer", otel.GetTracerProvider(), old.tracer, func() { otel.SetTracerProvider(next.tracer) })
+	}
+	if next.propagator != nil {
+		otel.SetTextMapPropagator(next.propagator)
+	}
+
+	o.logger, o.meter, o.tracer = next.logger, next.meter, next.tracer
+	o.promRegistry = next.promRegistry
+	o.propagator = next.propagator
+	o.metricsStart = next.metricsStart
+	return old
+}
+
+// Shutdown gracefully shuts down all providers, giving each at most
+// Config.ShutdownTimeout on top of any deadline already set on ctx
 func (o *Otel) Shutdown(ctx context.Context) error {
+	o.mu.Lock()
+	if o.heartbeatStop != nil {
+		close(o.heartbeatStop)
+		o.heartbeatStop = nil
+	}
+	o.mu.Unlock()
+
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	return o.shutdownProviders(ctx)
+}
+
+// shutdownProviders shuts down the current providers, each bounded by the
+// shutdown timeout
+func (o *Otel) shutdownProviders(ctx context.Context) error {
+	timeout := o.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+
 	var errs []error
 	if o.logger != nil {
-		if err := o.logger.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
-		}
+		errs = append(errs, shutdownWithTimeout(ctx, timeout, o.logger.Shutdown))
 	}
 	if o.meter != nil {
-		if err := o.meter.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
-		}
+		errs = append(errs, shutdownWithTimeout(ctx, timeout, o.meter.Shutdown))
 	}
 	if o.tracer != nil {
-		if err := o.tracer.Shutdown(ctx); err != nil {
-			errs = append(errs, err)
-		}
+		errs = append(errs, shutdownWithTimeout(ctx, timeout, o.tracer.Shutdown))
 	}
 	return errors.Join(errs...)
 }
 
+// Reconfigure replaces the providers with ones built from cfg, e.g. to move to
+// another collector without restarting. The new providers take over as globals
+// from this instance's current ones, which are then flushed and shut down.
+// An invalid cfg, or one whose providers fail to initialize without
+// BestEffort, is rejected without changing anything. Tracers, recorders and
+// meters obtained before the call keep using the old providers and stop
+// recording, so fetch them again from this instance; a running heartbeat is
+// stopped and must be enabled again. The runtime log level only follows
+// cfg.LogLevel when it differs from the previous configuration's, so a level
+// set through SetLogLevel survives reloading an unchanged configuration.
+func (o *Otel) Reconfigure(ctx context.Context, cfg Config) error {
+	// the new providers are built outside the lock so span creation isn't
+	// blocked while the exporters are set up
+	next, setupErr := o.build(ctx, cfg.withEnv())
+	if next == nil {
+		return setupErr
+	}
+
+	o.stateMu.Lock()
+	o.mu.Lock()
+	if o.heartbeatStop != nil {
+		close(o.heartbeatStop)
+		o.heartbeatStop = nil
+	}
+	clear(o.recorders)
+	o.mu.Unlock()
+	o.tracers.Clear()
+
+	old := o.install(next)
+	if next.config.LogLevel != old.config.LogLevel {
+		o.logLevel.Set(next.config.LogLevel)
+	}
+	o.stateMu.Unlock()
+
+	// the old providers flush outside the lock so span creation isn't blocked
+	return errors.Join(setupErr, old.shutdownProviders(ctx))
+}
+
+// shutdownWithTimeout bounds a provider shutdown so an unreachable collector
+// can't block it past the timeout, even when ctx has no deadline
+func shutdownWithTimeout(ctx context.Context, timeout time.Duration, shutdown func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return shutdown(ctx)
+}
+
+// ForceFlush exports all buffered logs, metrics and spans without shutting the
+// providers down, e.g. before a short-lived job exits or a test asserts on them
+func (o *Otel) ForceFlush(ctx context.Context) error {
+	return errors.Join(o.FlushLogs(ctx), o.FlushMetrics(ctx), o.FlushTraces(ctx))
+}
+
+// FlushLogs exports buffered log records only; it does nothing before Setup
+func (o *Otel) FlushLogs(ctx context.Context) error {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	if o.logger == nil {
+		return nil
+	}
+	return o.logger.ForceFlush(ctx)
+}
+
+// FlushMetrics collects and exports metrics only, e.g. to emit a
+// point-in-time snapshot; it does nothing before Setup
+func (o *Otel) FlushMetrics(ctx context.Context) error {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	if o.meter == nil {
+		return nil
+	}
+	return o.meter.ForceFlush(ctx)
+}
+
+// FlushTraces exports ended spans only; it does nothing before Setup
+func (o *Otel) FlushTraces(ctx context.Context) error {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	if o.tracer == nil {
+		return nil
+	}
+	return o.tracer.ForceFlush(ctx)
+}
+
 // GetTracerProvider returns the tracer provider
 func (o *Otel) GetTracerProvider() *sdktrace.TracerProvider {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
 	return o.tracer
 }
 
+// LoggerProvider returns a logger provider emitting through this instance's
+// current provider, also after Reconfigure. Setup installs it as the global
+// one; pass it to WithLoggerProvider when another library's global provider
+// was kept.
+func (o *Otel) LoggerProvider() log.LoggerProvider {
+	return o.logs
+}
+
+// sdkLoggerProvider returns the current SDK logger provider
+func (o *Otel) sdkLoggerProvider() *sdklog.LoggerProvider {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	return o.logger
+}
+
 // GetMeterProvider returns the meter provider
 func (o *Otel) GetMeterProvider() *sdkmetric.MeterProvider {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
 	return o.meter
 }
 
-// commonHeaders returns the common headers for OTLP exporters
+// currentConfig returns the configuration in effect. Reconfigure replaces it,
+// so code running after Setup reads it through here instead of o.config.
+func (o *Otel) currentConfig() Config {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	return o.config
+}
+
+// snapshot returns an instance holding a copy of the configuration in effect,
+// for methods deriving several settings from it while Reconfigure may run
+func (o *Otel) snapshot() *Otel {
+	return &Otel{config: o.currentConfig(), logLevel: o.logLevel}
+}
+
+// Propagator returns the propagator installed by Setup, or the global one
+// before Setup
+func (o *Otel) Propagator() propagation.TextMapPropagator {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	if o.propagator == nil {
+		return otel.GetTextMapPropagator()
+	}
+	return o.propagator
+}
+
+// Tracer returns the tracer of the instrumentation scope name. Tracers are
+// cached per scope so creating spans doesn't look the tracer up every time.
+// Before Setup it returns a tracer from the global provider without caching it.
+func (o *Otel) Tracer(name string) trace.Tracer {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+
+	if o.tracer == nil {
+		return otel.GetTracerProvider().Tracer(name)
+	}
+	if tracer, ok := o.tracers.Load(name); ok {
+		return tracer.(trace.Tracer)
+	}
+	tracer, _ := o.tracers.LoadOrStore(name, o.tracer.Tracer(name))
+	return tracer.(trace.Tracer)
+}
+
+// StartSpan creates a new span from the cached tracer of DefaultScopeName
+func (o *Otel) StartSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return o.Tracer(DefaultScopeName).Start(ctx, name, opts...)
+}
+
+// WithSpan runs fn inside a span named name and always ends the span. An error
+// returned by fn is recorded with RecordTraceError and returned; otherwise the
+// span is marked successful. A panic in fn is recorded as an error, with the
+// stack of the panicking goroutine, before it propagates.
+func (o *Otel) WithSpan(ctx context.Context, name string, fn func(context.Context) error) error {
+	serviceName := o.currentConfig().ServiceName
+	ctx, span := o.StartSpan(ctx, name)
+	defer span.End()
+	defer func() {
+		if r := recover(); r != nil {
+			RecordTraceError(fmt.Errorf("panic: %v", r), serviceName, span)
+			panic(r)
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		RecordTraceError(err, serviceName, span)
+		return err
+	}
+	RecordTraceSuccessful(serviceName, span)
+	return nil
+}
+
+// MetricsRecorder returns the MetricsRecorder named name on the instance's
+// meter provider, creating it on first use. Recorders are memoized per name,
+// so subsystems sharing a name share one set of instruments instead of
+// registering duplicates.
+func (o *Otel) MetricsRecorder(name string) (*MetricsRecorder, error) {
+	// the read lock is held until the recorder is stored so Reconfigure can't
+	// swap the meter provider in between
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+
+	var meterProvider metric.MeterProvider = otel.GetMeterProvider()
+	if o.meter != nil {
+		meterProvider = o.meter
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if recorder, ok := o.recorders[name]; ok {
+		return recorder, nil
+	}
+	recorder, err := NewMetricsRecorder(meterProvider, name)
+	if err != nil {
+		return nil, err
+	}
+	o.recorders[name] = recorder
+	return recorder, nil
+}
+
+// RecorderFor returns the MetricsRecorder for a subsystem scope, creating it on
+// first use so rarely used subsystems don't register instruments at startup.
+// Creation errors are reported to the global error handler and yield a
+// recorder that discards measurements.
+func (o *Otel) RecorderFor(scope string) *MetricsRecorder {
+	recorder, err := o.MetricsRecorder(scope)
+	if err != nil {
+		otel.Handle(err)
+		recorder, _ = NewMetricsRecorder(noop.NewMeterProvider(), scope)
+	}
+	return recorder
+}
+
+// EnableHeartbeat registers a heartbeat gauge that always reports 1, so gaps in
+// the series show when the process was down regardless of request traffic. A
+// positive interval also flushes metrics at that pace so heartbeats are pushed
+// more often than the regular export interval; flushing stops on Shutdown.
+func (o *Otel) EnableHeartbeat(interval time.Duration) error {
+	// the read lock is held until the flush goroutine is registered so
+	// Reconfigure stops it instead of leaving it flushing a provider that was
+	// shut down
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+
+	meter := o.meter
+	if meter == nil {
+		return errors.New("otel: EnableHeartbeat requires Setup to be called first")
+	}
+
+	_, err := meter.Meter(DefaultScopeName).Int64ObservableGauge(
+		"heartbeat",
+		metric.WithDescription("Always 1 while the process is running"),
+		metric.WithInt64Callback(func(_ context.Context, obs metric.Int64Observer) error {
+			obs.Observe(1)
+			return nil
+		}),
+	)
+	if err != nil || interval <= 0 {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.heartbeatStop != nil {
+		close(o.heartbeatStop)
+	}
+	stop := make(chan struct{})
+	o.heartbeatStop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+				if err := meter.ForceFlush(ctx); err != nil {
+					otel.Handle(err)
+				}
+				cancel()
+			}
+		}
+	}()
+	return nil
+}
+
+// LogLevel returns the runtime-adjustable log level, initially Config.LogLevel.
+// Pass it to NewOtelHandler with WithLevel to let SetLogLevel control the handler.
+func (o *Otel) LogLevel() *slog.LevelVar {
+	return o.logLevel
+}
+
+// SetLogLevel changes the level of every handler created with WithLevel(o.LogLevel())
+func (o *Otel) SetLogLevel(level slog.Level) {
+	o.logLevel.Set(level)
+}
+
+// String describes the effective configuration for diagnostics, with the token
+// masked
+func (o *Otel) String() string {
+	c := o.snapshot()
+	compression := c.config.Compression
+	if compression == "" {
+		compression = "none"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "service: %s (version %q, environment %q)\n", c.config.ServiceName, c.config.ServiceVersion, c.config.Environment)
+	if c.config.Disabled {
+		fmt.Fprintf(&b, "telemetry: disabled")
+		return b.String()
+	}
+	if c.stdout() {
+		fmt.Fprintf(&b, "exporter: stdout\n")
+	} else {
+		if c.useHTTP() {
+			fmt.Fprintf(&b, "protocol: http (insecure)\n")
+			fmt.Fprintf(&b, "endpoints: traces=%s%s metrics=%s%s logs=%s%s\n",
+				c.config.Host, cmp.Or(c.config.TracePath, "/v1/traces"),
+				c.config.Host, cmp.Or(c.config.MetricPath, "/v1/metrics"),
+				c.config.Host, cmp.Or(c.config.LogPath, "/v1/logs"))
+		} else {
+			fmt.Fprintf(&b, "protocol: grpc (insecure)\n")
+			fmt.Fprintf(&b, "endpoints: traces=%s metrics=%s logs=%s\n", c.config.Host, c.config.Host, c.config.Host)
+		}
+		fmt.Fprintf(&b, "headers: %v\n", redactHeaders(c.commonHeaders()))
+	}
+	fmt.Fprintf(&b, "sampling: %s\n", c.sampler().Description())
+	fmt.Fprintf(&b, "compression: %s\n", compression)
+	retry := c.retry()
+	fmt.Fprintf(&b, "retry: enabled=%t initial=%s max=%s elapsed=%s\n", retry.Enabled, retry.InitialInterval, retry.MaxInterval, retry.MaxElapsedTime)
+	if c.config.UseSimpleSpanProcessor {
+		fmt.Fprintf(&b, "traces: simple (synchronous export)\n")
+	} else {
+		fmt.Fprintf(&b, "traces: batch queue=%d\n", traceQueueSize)
+	}
+	if c.config.MetricsExporter == ExporterPrometheus {
+		fmt.Fprintf(&b, "metrics: prometheus pull only\n")
+	} else {
+		fmt.Fprintf(&b, "metrics: interval=%s timeout=%s temporality=%s prometheus=%t\n", metricInterval, exportTimeout, cmp.Or(c.config.MetricTemporality, TemporalityCumulative), c.config.PrometheusScrape)
+	}
+	fmt.Fprintf(&b, "logs: batch interval=%s timeout=%s queue=%d level=%s", logExportInterval, exportTimeout, logQueueSize, c.logLevel.Level())
+	return b.String()
+}
+
+// redactHeaders returns a copy of headers with credential values masked
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if isCredentialHeader(k) && v != "" {
+			v = "***"
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+// isCredentialHeader reports whether a header likely carries a credential, which
+// covers Authorization and custom headers such as X-Api-Key or X-Auth-Token
+func isCredentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range []string{"auth", "token", "key", "secret", "password"} {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// MetricsStartTime returns when the meter provider was created. Cumulative
+// points exported over OTLP carry a start timestamp from this process run, so
+// a backend sees a new start time after every restart and can treat the drop
+// in a counter as a reset rather than a negative rate.
+func (o *Otel) MetricsStartTime() time.Time {
+	o.stateMu.RLock()
+	defer o.stateMu.RUnlock()
+	return o.metricsStart
+}
+
+// commonHeaders returns the common headers for OTLP exporters, with
+// Config.Headers overriding the built-in ones regardless of case
 func (o *Otel) commonHeaders() map[string]string {
-	return map[string]string{
-		"Authorization": o.config.Token,
-		"organization":  o.config.Organization,
-		"stream-name":   o.config.StreamName,
+	headers := map[string]string{
+		"organization": o.config.Organization,
+		"stream-name":  o.config.StreamName,
 	}
+	if o.config.Token != "" {
+		authorization := o.config.Token
+		if o.config.AuthScheme != "" {
+			authorization = o.config.AuthScheme + " " + o.config.Token
+		}
+		headers["Authorization"] = authorization
+	}
+	for k, v := range o.config.Headers {
+		for existing := range headers {
+			if existing != k && strings.EqualFold(existing, k) {
+				delete(headers, existing)
+			}
+		}
+		headers[k] = v
+	}
+	return headers
 }
 
 // commonResource creates a common resource configuration
 func (o *Otel) commonResource(ctx context.Context) (*resource.Resource, error) {
-	return resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(o.config.ServiceName),
-			semconv.DeploymentEnvironment(o.config.Environment),
-		),
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(o.config.ServiceName),
+		semconv.DeploymentEnvironment(o.config.Environment),
+	}
+	if o.config.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(o.config.ServiceVersion))
+	}
+
+	// user attributes are applied last so they override the built-in ones
+	custom := make([]attribute.KeyValue, 0, len(o.config.ResourceAttributes))
+	for k, v := range o.config.ResourceAttributes {
+		custom = append(custom, attribute.String(k, v))
+	}
+
+	opts := []resource.Option{
+		resource.WithAttributes(attrs...),
 		resource.WithProcessRuntimeDescription(),
 		resource.WithTelemetrySDK(),
-	)
+	}
+	if o.config.DetectResources {
+		opts = append(opts,
+			resource.WithHost(),
+			resource.WithContainer(),
+			resource.WithFromEnv(),
+		)
+	}
+	opts = append(opts, resource.WithAttributes(custom...))
+
+	res, err := resource.New(ctx, opts...)
+	if err != nil && o.config.DetectResources {
+		// detection is best effort; keep whatever the detectors produced
+		otel.Handle(err)
+		return res, nil
+	}
+	return res, err
 }
 
 // initLoggerProvider initializes the logger provider
 func (o *Otel) initLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
-	exporter, err := otlploggrpc.New(
-		ctx,
-		otlploggrpc.WithEndpoint(o.config.Host),
-		otlploggrpc.WithInsecure(),
-		otlploggrpc.WithHeaders(o.commonHeaders()),
-	)
+	exporter, err := o.newLogExporter(ctx)
 	if err != nil {
-		return nil, err
+		return nil, o.exporterInitError("logs", err)
 	}
 
 	res, err := o.commonResource(ctx)
@@ -150,47 +687,71 @@ func (o *Otel) initLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider,
 		sdklog.WithResource(res),
 		sdklog.WithProcessor(sdklog.NewBatchProcessor(
 			exporter,
-			sdklog.WithExportInterval(1*time.Second),
-			sdklog.WithExportTimeout(5*time.Second),
-			sdklog.WithMaxQueueSize(2048),
+			sdklog.WithExportInterval(logExportInterval),
+			sdklog.WithExportTimeout(exportTimeout),
+			sdklog.WithMaxQueueSize(logQueueSize),
 		)),
 	), nil
 }
 
-// initMeterProvider initializes the meter provider
+// initMeterProvider initializes the meter provider.
+//
+// Metrics use cumulative temporality unless Config.MetricTemporality selects
+// delta: each exported sum carries the start time of its instrument, which is
+// reset whenever the process starts. Backends that derive rates (e.g.
+// Prometheus' _created series) use it to detect resets. With delta temporality
+// each point covers only the last collection interval, so a restart produces
+// no spike but the first interval after it is lost.
 func (o *Otel) initMeterProvider(ctx context.Context) (*sdkmetric.MeterProvider, error) {
 	res, err := o.commonResource(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	exporter, err := otlpmetricgrpc.New(
-		ctx,
-		otlpmetricgrpc.WithEndpoint(o.config.Host),
-		otlpmetricgrpc.WithInsecure(),
-		otlpmetricgrpc.WithHeaders(o.commonHeaders()),
-		otlpmetricgrpc.WithTimeout(5*time.Second),
-		otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
-			Enabled:         true,
-			InitialInterval: 1 * time.Second,
-			MaxInterval:     10 * time.Second,
-			MaxElapsedTime:  30 * time.Second,
-		}),
-	)
-	if err != nil {
-		return nil, err
+	providerOpts := []sdkmetric.Option{
+		sdkmetric.WithResource(res),
+		sdkmetric.WithExemplarFilter(exemplarFilter(o.config.ExemplarFilter)),
+	}
+	if len(o.config.Views) > 0 {
+		providerOpts = append(providerOpts, sdkmetric.WithView(o.config.Views...))
+	}
+	if o.config.MetricsExporter != ExporterPrometheus {
+		exporter, err := o.newMetricExporter(ctx)
+		if err != nil {
+			return nil, o.exporterInitError("metrics", err)
+		}
+		providerOpts = append(providerOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(
+			exporter,
+			sdkmetric.WithInterval(metricInterval),
+			sdkmetric.WithTimeout(exportTimeout),
+		)))
+	}
+	if o.config.PrometheusScrape || o.config.MetricsExporter == ExporterPrometheus {
+		registry := prometheus.NewRegistry()
+		promExporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+		if err != nil {
+			return nil, &ExporterInitError{Signal: "metrics", Endpoint: ExporterPrometheus, Err: err}
+		}
+		providerOpts = append(providerOpts, sdkmetric.WithReader(promExporter))
+		o.promRegistry = registry
 	}
 
-	reader := sdkmetric.NewPeriodicReader(
-		exporter,
-		sdkmetric.WithInterval(10*time.Second),
-		sdkmetric.WithTimeout(5*time.Second),
-	)
+	return sdkmetric.NewMeterProvider(providerOpts...), nil
+}
 
-	return sdkmetric.NewMeterProvider(
-		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(reader),
-	), nil
+// exemplarFilter returns the exemplar filter by name, falling back to
+// OTEL_METRICS_EXEMPLAR_FILTER and then to trace_based. With trace_based,
+// measurements taken with a context holding a sampled span carry its trace and
+// span ID, so a slow histogram bucket links to an example trace.
+func exemplarFilter(name string) exemplar.Filter {
+	switch cmp.Or(name, os.Getenv("OTEL_METRICS_EXEMPLAR_FILTER")) {
+	case "always_on":
+		return exemplar.AlwaysOnFilter
+	case "always_off":
+		return exemplar.AlwaysOffFilter
+	default:
+		return exemplar.TraceBasedFilter
+	}
 }
 
 // initTracerProvider initializes the tracer provider
@@ -200,31 +761,52 @@ func (o *Otel) initTracerProvider(ctx context.Context) (*sdktrace.TracerProvider
 		return nil, err
 	}
 
-	exporter, err := otlptracegrpc.New(
-		ctx,
-		otlptracegrpc.WithEndpoint(o.config.Host),
-		otlptracegrpc.WithInsecure(),
-		otlptracegrpc.WithHeaders(o.commonHeaders()),
-		otlptracegrpc.WithTimeout(5*time.Second),
-		otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
-			Enabled:         true,
-			InitialInterval: 1 * time.Second,
-			MaxInterval:     10 * time.Second,
-			MaxElapsedTime:  30 * time.Second,
-		}),
-	)
+	exporter, err := o.newTraceExporter(ctx)
 	if err != nil {
-		return nil, err
+		return nil, o.exporterInitError("traces", err)
 	}
 
-	sampler := sdktrace.AlwaysSample()
-	if o.config.SampleRate > 0 {
-		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(o.config.SampleRate))
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(o.sampler()),
+	}
+	if len(o.config.DefaultSpanAttributes) > 0 {
+		providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(defaultAttributesProcessor{attrs: o.config.DefaultSpanAttributes}))
 	}
 
-	return sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(res),
-		sdktrace.WithSampler(sampler),
-	), nil
+	if o.config.UseSimpleSpanProcessor {
+		// spans are exported as they end, so there is no queue to observe
+		providerOpts = append(providerOpts, sdktrace.WithSyncer(exporter))
+		return sdktrace.NewTracerProvider(providerOpts...), nil
+	}
+
+	queue := newQueueObserver(traceQueueSize, o.config.TraceQueueThreshold, o.config.OnTraceQueueSaturation)
+	queue.SpanProcessor = sdktrace.NewBatchSpanProcessor(
+		queue.wrapExporter(exporter),
+		sdktrace.WithMaxQueueSize(traceQueueSize),
+		sdktrace.WithMaxExportBatchSize(traceBatchSize),
+	)
+	if o.meter != nil {
+		if err := queue.registerMetrics(o.meter.Meter(DefaultScopeName)); err != nil {
+			return nil, err
+		}
+	}
+	providerOpts = append(providerOpts, sdktrace.WithSpanProcessor(queue))
+
+	return sdktrace.NewTracerProvider(providerOpts...), nil
+}
+
+// sampler returns the trace sampler selected by the configuration
+func (o *Otel) sampler() sdktrace.Sampler {
+	if o.config.Sampler != nil {
+		return o.config.Sampler
+	}
+	parentOpts := o.config.Sampling.parentBasedOptions()
+	if rate := o.config.sampleRate(); rate > 0 {
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(rate), parentOpts...)
+	}
+	if len(parentOpts) > 0 {
+		return sdktrace.ParentBased(sdktrace.AlwaysSample(), parentOpts...)
+	}
+	return sdktrace.AlwaysSample()
 }
@@ -0,0 +1,259 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// newTestOtel returns an instance using a tracer provider built from opts,
+// without installing global providers
+func newTestOtel(t testing.TB, opts ...sdktrace.TracerProviderOption) *Otel {
+	t.Helper()
+	tracer := sdktrace.NewTracerProvider(opts...)
+	t.Cleanup(func() { tracer.Shutdown(context.Background()) })
+
+	o := New(Config{ServiceName: "test", Exporter: ExporterStdout})
+	o.tracer = tracer
+	return o
+}
+
+func BenchmarkStartSpan(b *testing.B) {
+	tracer := newTestOtel(b).GetTracerProvider()
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		_, span := StartSpan(ctx, tracer, "op")
+		span.End()
+	}
+}
+
+func BenchmarkOtelStartSpan(b *testing.B) {
+	o := newTestOtel(b)
+	ctx := context.Background()
+	b.ReportAllocs()
+	for b.Loop() {
+		_, span := o.StartSpan(ctx, "op")
+		span.End()
+	}
+}
+
+func BenchmarkOtelStartSpanParallel(b *testing.B) {
+	o := newTestOtel(b)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			_, span := o.StartSpan(ctx, "op")
+			span.End()
+		}
+	})
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReconfigureKeepsHandlers(t *testing.T) {
+	ctx := context.Background()
+	var before, after syncBuffer
+	o := New(Config{ServiceName: "test", Exporter: ExporterStdout, StdoutWriter: &before, OverwriteGlobals: true})
+	if err := o.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	defer o.Shutdown(ctx)
+
+	// the handler emits through the global provider installed by Setup
+	h, err := NewOtelHandler(nil, "test")
+	if err != nil {
+		t.Fatalf("NewOtelHandler: %v", err)
+	}
+	logger := slog.New(h)
+
+	if err := o.Reconfigure(ctx, Config{ServiceName: "test", Exporter: ExporterStdout, StdoutWriter: &after, OverwriteGlobals: true}); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	logger.Info("after reconfigure")
+	if err := o.FlushLogs(ctx); err != nil {
+		t.Fatalf("FlushLogs: %v", err)
+	}
+
+	if !strings.Contains(after.String(), "after reconfigure") {
+		t.Errorf("record was not exported by the new provider; got %q", after.String())
+	}
+	if strings.Contains(before.String(), "after reconfigure") {
+		t.Error("record was exported by the old provider")
+	}
+}
+
+func TestReconfigureKeepsRuntimeLogLevel(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{ServiceName: "test", Exporter: ExporterStdout, StdoutWriter: io.Discard, OverwriteGlobals: true}
+	o := New(cfg)
+	if err := o.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	defer o.Shutdown(ctx)
+
+	o.SetLogLevel(slog.LevelDebug)
+	if err := o.Reconfigure(ctx, cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	if got := o.LogLevel().Level(); got != slog.LevelDebug {
+		t.Errorf("level after reloading the same configuration = %v, want %v", got, slog.LevelDebug)
+	}
+
+	cfg.LogLevel = slog.LevelWarn
+	if err := o.Reconfigure(ctx, cfg); err != nil {
+		t.Fatalf("Reconfigure: %v", err)
+	}
+	if got := o.LogLevel().Level(); got != slog.LevelWarn {
+		t.Errorf("level after changing Config.LogLevel = %v, want %v", got, slog.LevelWarn)
+	}
+}
+
+func TestConcurrentUseDuringReconfigure(t *testing.T) {
+	ctx := context.Background()
+	cfg := Config{ServiceName: "test", Exporter: ExporterStdout, StdoutWriter: io.Discard, OverwriteGlobals: true}
+	o := New(cfg)
+	if err := o.Setup(ctx); err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	defer o.Shutdown(ctx)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				_, span := o.Tracer("worker").Start(ctx, "direct")
+				span.End()
+				o.WithSpan(ctx, "wrapped", func(context.Context) error { return nil })
+				if recorder, err := o.MetricsRecorder("worker"); err == nil {
+					recorder.RecordAcceptedRequest(ctx)
+				}
+				if err := o.EnableHeartbeat(time.Hour); err != nil {
+					t.Errorf("EnableHeartbeat: %v", err)
+				}
+				_ = o.String()
+				_ = o.MetricsStartTime()
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 5 {
+			cfg.ServiceVersion = strconv.Itoa(i)
+			if err := o.Reconfigure(ctx, cfg); err != nil {
+				t.Errorf("Reconfigure: %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+
+	recorder, err := o.MetricsRecorder("worker")
+	if err != nil {
+		t.Fatalf("MetricsRecorder: %v", err)
+	}
+	if recorder.meter != o.GetMeterProvider().Meter("worker") {
+		t.Error("memoized recorder uses a meter provider replaced by Reconfigure")
+	}
+}
+
+func TestWithSpanRecordsPanic(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	o := newTestOtel(t, sdktrace.WithSpanProcessor(recorder))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the original panic value", r)
+			}
+		}()
+		o.WithSpan(context.Background(), "panicking", func(context.Context) error {
+			panic("boom")
+		})
+	}()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d ended spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Status().Code != codes.Error || span.Status().Description != "panic: boom" {
+		t.Errorf("status = %+v, want Error with description %q", span.Status(), "panic: boom")
+	}
+	// the SDK adds its own exception event when a span ends during a panic
+	var exception bool
+	for _, event := range span.Events() {
+		for _, attr := range event.Attributes {
+			if event.Name == semconv.ExceptionEventName && attr.Key == semconv.ExceptionMessageKey && attr.Value.AsString() == "panic: boom" {
+				exception = true
+			}
+		}
+	}
+	if !exception {
+		t.Errorf("span has no exception event for %q; events: %v", "panic: boom", span.Events())
+	}
+}
+
+func TestCommonHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   map[string]string
+	}{
+		{
+			name:   "token",
+			config: Config{Token: "secret", AuthScheme: "Bearer", Organization: "org"},
+			want:   map[string]string{"Authorization": "Bearer secret", "organization": "org", "stream-name": ""},
+		},
+		{
+			name:   "no token",
+			config: Config{Organization: "org", StreamName: "stream"},
+			want:   map[string]string{"organization": "org", "stream-name": "stream"},
+		},
+		{
+			name:   "lowercase override",
+			config: Config{Token: "secret", Headers: map[string]string{"authorization": "Bearer x", "Stream-Name": "custom"}},
+			want:   map[string]string{"authorization": "Bearer x", "organization": "", "Stream-Name": "custom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "")
+
+			if got := New(tt.config).commonHeaders(); !maps.Equal(got, tt.want) {
+				t.Errorf("commonHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package otel
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Trace batching limits. traceQueueSize bounds the ended spans waiting for
+// export, whether queued or in the batch being exported.
+const (
+	traceBatchSize = sdktrace.DefaultMaxExportBatchSize
+	traceQueueSize = sdktrace.DefaultMaxQueueSize + traceBatchSize
+)
+
+// queueObserver wraps a batch span processor and tracks how many ended spans
+// are waiting to be exported: sampled spans handed to the batcher that the
+// exporter has not finished with yet. Spans beyond its capacity are dropped by
+// the observer itself, so every drop is counted; given a batcher queue of the
+// same capacity, the batcher never overflows on its own.
+type queueObserver struct {
+	sdktrace.SpanProcessor
+	capacity     int64
+	threshold    int64
+	onSaturation func(depth, capacity int)
+	depth        atomic.Int64
+	saturated    atomic.Bool
+	dropped      atomic.Int64
+}
+
+// newQueueObserver creates an observer for a queue of the given capacity. A
+// threshold in (0, 1] enables the saturation callback.
+func newQueueObserver(capacity int, threshold float64, onSaturation func(depth, capacity int)) *queueObserver {
+	q := &queueObserver{
+		capacity:     int64(capacity),
+		onSaturation: onSaturation,
+	}
+	if threshold > 0 && onSaturation != nil {
+		q.threshold = max(int64(threshold*float64(capacity)), 1)
+	}
+	return q
+}
+
+// OnEnd counts sampled spans entering the queue before forwarding them, and
+// drops them instead when the queue is full
+func (q *queueObserver) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		depth := q.depth.Add(1)
+		if depth > q.capacity {
+			// the span never enters the queue, so it doesn't count towards the depth
+			q.depth.Add(-1)
+			q.dropped.Add(1)
+			return
+		}
+		if q.threshold > 0 && depth >= q.threshold && q.saturated.CompareAndSwap(false, true) {
+			q.onSaturation(int(depth), int(q.capacity))
+		}
+	}
+	q.SpanProcessor.OnEnd(s)
+}
+
+// release removes exported spans from the tracked depth and re-arms the callback
+func (q *queueObserver) release(n int) {
+	if depth := q.depth.Add(-int64(n)); depth < q.threshold {
+		q.saturated.Store(false)
+	}
+}
+
+// wrapExporter returns an exporter that reports exported spans back to the observer
+func (q *queueObserver) wrapExporter(exporter sdktrace.SpanExporter) sdktrace.SpanExporter {
+	return &observedExporter{SpanExporter: exporter, queue: q}
+}
+
+// registerMetrics registers a gauge reporting the queue utilization (depth /
+// capacity) and a counter of the spans dropped because the queue was full
+func (q *queueObserver) registerMetrics(meter metric.Meter) error {
+	_, err := meter.Float64ObservableGauge(
+		"otel_client_trace_queue_utilization",
+		metric.WithDescription("Fraction of the trace batch queue currently in use"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(float64(q.depth.Load()) / float64(q.capacity))
+			return nil
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.Int64ObservableCounter(
+		"otel_client_spans_dropped",
+		metric.WithDescription("Number of spans dropped because the trace batch queue was full"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(q.dropped.Load())
+			return nil
+		}),
+	)
+	return err
+}
+
+// observedExporter notifies a queueObserver whenever a batch leaves the queue
+type observedExporter struct {
+	sdktrace.SpanExporter
+	queue *queueObserver
+}
+
+// ExportSpans exports the batch and releases it from the tracked depth
+func (e *observedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	defer e.queue.release(len(spans))
+	return e.SpanExporter.ExportSpans(ctx, spans)
+}
+
+// defaultAttributesProcessor sets fixed attributes on every span when it starts
+type defaultAttributesProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+// OnStart adds the default attributes that the span doesn't already set itself
+func (p defaultAttributesProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	existing := make(map[attribute.Key]struct{}, len(s.Attributes()))
+	for _, kv := range s.Attributes() {
+		existing[kv.Key] = struct{}{}
+	}
+	for _, kv := range p.attrs {
+		if _, ok := existing[kv.Key]; !ok {
+			s.SetAttributes(kv)
+		}
+	}
+}
+
+// OnEnd does nothing
+func (defaultAttributesProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown does nothing
+func (defaultAttributesProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush does nothing
+func (defaultAttributesProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,72 @@
+package otel
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// blockingExporter holds every export until release is closed
+type blockingExporter struct {
+	release  chan struct{}
+	exported atomic.Int64
+}
+
+func (e *blockingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	select {
+	case <-e.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+func (e *blockingExporter) Shutdown(context.Context) error { return nil }
+
+func TestQueueObserverDrops(t *testing.T) {
+	tests := []struct {
+		name        string
+		spans       int
+		wantDropped int64
+	}{
+		{"within capacity", 2300, 0},
+		{"over capacity", traceQueueSize + 300, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			exporter := &blockingExporter{release: make(chan struct{})}
+			queue := newQueueObserver(traceQueueSize, 0, nil)
+			queue.SpanProcessor = sdktrace.NewBatchSpanProcessor(
+				queue.wrapExporter(exporter),
+				sdktrace.WithMaxQueueSize(traceQueueSize),
+				sdktrace.WithMaxExportBatchSize(traceBatchSize),
+			)
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(queue))
+			defer provider.Shutdown(ctx)
+
+			tracer := provider.Tracer("test")
+			for range tt.spans {
+				_, span := tracer.Start(ctx, "span")
+				span.End()
+			}
+			if got := queue.dropped.Load(); got != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", got, tt.wantDropped)
+			}
+
+			close(exporter.release)
+			if err := provider.ForceFlush(ctx); err != nil {
+				t.Fatalf("ForceFlush: %v", err)
+			}
+			if got, want := exporter.exported.Load(), int64(tt.spans)-tt.wantDropped; got != want {
+				t.Errorf("exported = %d, want %d", got, want)
+			}
+			if got := queue.depth.Load(); got != 0 {
+				t.Errorf("depth after flush = %d, want 0", got)
+			}
+		})
+	}
+}
@@ -1,125 +1,584 @@
 package otel
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"math/rand/v2"
+	"os"
 	"runtime"
+	"slices"
 	"strconv"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// otelHandler implements slog.Handler and emits logs to OTEL + slog output
+// DebugGroup is the attribute group whose members are only emitted for Debug records
+const DebugGroup = "debug"
+
+// DebugAttrs groups verbose attributes under DebugGroup so they are dropped from
+// records above Debug level
+func DebugAttrs(args ...any) slog.Attr {
+	return slog.Group(DebugGroup, args...)
+}
+
+// otelHandler implements slog.Handler and emits logs to OTEL + a downstream handler
 type otelHandler struct {
+	provider   log.LoggerProvider
 	otelLogger log.Logger
-	logger     *slog.Logger
-	attrs      []slog.Attr
-	group      string
+	next       slog.Handler
+	level      slog.Leveler
+	dedup      *dedupCache
+	sampling   []levelRate // sorted by ascending level
+	attrs      []handlerAttr
+	prefix     string // open groups joined as "group1.group2."
+	metrics    *handlerMetrics
+	baggage    bool
+	mapBody    bool
+	ctxAttrs   func(context.Context) []slog.Attr
+	source     bool
+	redact     func(key string) bool
+}
+
+// handlerMetrics counts the records handled by an otelHandler
+type handlerMetrics struct {
+	emitted    metric.Int64Counter
+	suppressed metric.Int64Counter
+}
+
+// handlerAttr is an attribute added with WithAttrs and the group prefix that was
+// open when it was added
+type handlerAttr struct {
+	prefix string
+	attr   slog.Attr
+}
+
+// HandlerOption configures the handler created by NewOtelHandler
+type HandlerOption func(*otelHandler)
+
+// WithLevel sets the minimum level the handler emits. Pass a *slog.LevelVar
+// (e.g. Otel.LogLevel) to change it at runtime.
+func WithLevel(level slog.Leveler) HandlerOption {
+	return func(h *otelHandler) {
+		h.level = level
+	}
+}
+
+// WithTraceDedup suppresses Error records repeating the same message within the
+// same trace for the given window, e.g. from a retry loop. The number of
+// suppressed repeats is reported as log.repeat_count on the next record emitted
+// for that trace and message. Deduplication is off by default.
+func WithTraceDedup(window time.Duration) HandlerOption {
+	return func(h *otelHandler) {
+		if window > 0 {
+			h.dedup = newDedupCache(window)
+		}
+	}
+}
+
+// WithHandlerMetrics counts the records the handler emits by severity
+// (otel_client_log_records_emitted) and the records it suppresses through log
+// sampling or deduplication by reason (otel_client_log_records_suppressed).
+// Both count at the handler only: records the log processor later loses, e.g.
+// when its queue overflows, are not covered. Instrument creation errors are
+// reported to the global error handler and leave the metrics disabled.
+func WithHandlerMetrics(meter metric.Meter) HandlerOption {
+	return func(h *otelHandler) {
+		emitted, err := meter.Int64Counter("otel_client_log_records_emitted",
+			metric.WithDescription("Number of log records emitted by the slog handler"))
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		suppressed, err := meter.Int64Counter("otel_client_log_records_suppressed",
+			metric.WithDescription("Number of log records the slog handler suppressed through sampling or deduplication"))
+		if err != nil {
+			otel.Handle(err)
+			return
+		}
+		h.metrics = &handlerMetrics{emitted: emitted, suppressed: suppressed}
+	}
+}
+
+// WithBaggage attaches the members of the context baggage to every record as
+// attributes named "baggage.<key>", e.g. a tenant or user ID set upstream
+func WithBaggage() HandlerOption {
+	return func(h *otelHandler) {
+		h.baggage = true
+	}
+}
+
+// WithContextAttrs adds the attributes fn extracts from the context of each
+// record, e.g. a request ID set by a middleware, at the top level next to the
+// trace correlation fields
+func WithContextAttrs(fn func(context.Context) []slog.Attr) HandlerOption {
+	return func(h *otelHandler) {
+		h.ctxAttrs = fn
+	}
+}
+
+// WithSource controls whether records carry the file:line of the logging call
+// as the source attribute (default true). Disabling it skips resolving the
+// call site, which is noticeable under high log volume.
+func WithSource(enabled bool) HandlerOption {
+	return func(h *otelHandler) {
+		h.source = enabled
+	}
+}
+
+// WithRedactedKeys replaces the values of attributes with the given keys, at
+// any group depth, with "***" before records reach OTEL or the terminal
+func WithRedactedKeys(keys ...string) HandlerOption {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return WithRedactFunc(func(key string) bool {
+		_, ok := set[key]
+		return ok
+	})
+}
+
+// WithRedactFunc replaces the values of attributes for which redact reports
+// true with "***". redact receives the attribute key without group prefixes.
+func WithRedactFunc(redact func(key string) bool) HandlerOption {
+	return func(h *otelHandler) {
+		h.redact = redact
+	}
+}
+
+// WithMapBody emits the record body as a map holding the message under
+// "message" together with the attributes, for backends that index a single
+// structured body. By default the body is the message string and the
+// attributes are record attributes.
+func WithMapBody() HandlerOption {
+	return func(h *otelHandler) {
+		h.mapBody = true
+	}
+}
+
+// levelRate is the sampling rate applied from a level upwards
+type levelRate struct {
+	level slog.Level
+	rate  float64
+}
+
+// WithLogSampling samples records independently of the trace sampler. Each
+// rate (0 to 1) applies to records at its level and above up to the next
+// configured level, e.g. {slog.LevelInfo: 0.1, slog.LevelError: 1} keeps 10%
+// of Info and Warn records and every Error; levels below the lowest entry are
+// always kept. Records belonging to a sampled trace are always kept so traces
+// stay correlated with their logs.
+func WithLogSampling(rates map[slog.Level]float64) HandlerOption {
+	return func(h *otelHandler) {
+		h.sampling = h.sampling[:0]
+		for level, rate := range rates {
+			h.sampling = append(h.sampling, levelRate{level: level, rate: rate})
+		}
+		slices.SortFunc(h.sampling, func(a, b levelRate) int {
+			return cmp.Compare(a.level, b.level)
+		})
+	}
+}
+
+// WithLoggerProvider emits through the given provider instead of the global
+// one installed by Setup
+func WithLoggerProvider(provider log.LoggerProvider) HandlerOption {
+	return func(h *otelHandler) {
+		h.provider = provider
+	}
+}
+
+// WithTerminalOutput controls whether records are also passed to the terminal
+// logger or downstream handler (default true). Disable it to only ship logs to
+// the collector.
+func WithTerminalOutput(enabled bool) HandlerOption {
+	return func(h *otelHandler) {
+		if !enabled {
+			h.next = nil
+		}
+	}
+}
+
+// NewOtelHandler creates a new handler that emits to OTEL and to the terminal
+// logger l, which may be nil to only emit to OTEL. It fails when no logger
+// provider is available, i.e. Setup has not run yet and WithLoggerProvider was
+// not used, since records would be dropped.
+func NewOtelHandler(l *slog.Logger, name string, opts ...HandlerOption) (slog.Handler, error) {
+	var next slog.Handler
+	if l != nil {
+		next = l.Handler()
+	}
+	return WrapHandler(next, name, opts...)
+}
+
+// Format is the encoding of the terminal output built by NewOtelHandlerWithOptions
+type Format string
+
+// Terminal output formats
+const (
+	FormatJSON Format = "json"
+	FormatText Format = "text"
+)
+
+// NewOtelHandlerWithOptions creates a handler that emits to OTEL and writes
+// every record to w (os.Stdout when nil) in the given format, sparing callers
+// from building the terminal handler themselves. Use WithLevel to filter.
+func NewOtelHandlerWithOptions(name string, format Format, w io.Writer, opts ...HandlerOption) (slog.Handler, error) {
+	if w == nil {
+		w = os.Stdout
+	}
+	// the OTEL handler applies the level, so the terminal handler accepts everything
+	handlerOpts := &slog.HandlerOptions{Level: slog.Level(math.MinInt)}
+
+	var next slog.Handler
+	switch format {
+	case FormatJSON:
+		next = slog.NewJSONHandler(w, handlerOpts)
+	case FormatText:
+		next = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("otel: Format must be %q or %q, got %q", FormatJSON, FormatText, format)
+	}
+	return WrapHandler(next, name, opts...)
 }
 
-// NewOtelHandler creates a new handler
-func NewOtelHandler(l *slog.Logger, name string) slog.Handler {
-	return &otelHandler{
-		otelLogger: global.GetLoggerProvider().Logger(name),
-		logger:     l,
+// WrapHandler adds OTEL emission on top of next, which receives every record
+// together with the handler attributes and trace correlation fields; a nil
+// next only emits to OTEL. It fails like NewOtelHandler when no logger
+// provider is available.
+func WrapHandler(next slog.Handler, name string, opts ...HandlerOption) (slog.Handler, error) {
+	h := &otelHandler{next: next, source: true}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if isNil(h.provider) {
+		h.provider = global.GetLoggerProvider()
 	}
+	if isGlobalDelegate(h.provider) {
+		return nil, errors.New("otel: no logger provider is set; call Setup before creating the handler or pass WithLoggerProvider")
+	}
+	h.otelLogger = h.provider.Logger(name)
+	return h, nil
 }
 
-// Enabled always returns true
+// Enabled reports whether the level reaches the configured minimum; without
+// WithLevel every level is enabled
 func (h *otelHandler) Enabled(_ context.Context, level slog.Level) bool {
-	return true
+	return h.level == nil || level >= h.level.Level()
 }
 
-// Handle emits the log record to OTEL and slog output
+// Handle emits the log record to OTEL and the downstream handler
 func (h *otelHandler) Handle(ctx context.Context, r slog.Record) error {
-	attrs := make([]log.KeyValue, 0, len(h.attrs)+r.NumAttrs()+4) // for trace_id and span_id
-	logAttrs := make([]any, 0, len(h.attrs)*2+r.NumAttrs()*2+4)
+	// wrapping handlers may call Handle without consulting Enabled first
+	if !h.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if !h.sampled(ctx, r.Level) {
+		h.recordSuppressed(ctx, "sampling")
+		return nil
+	}
+
+	var repeats int
+	if h.dedup != nil && r.Level >= slog.LevelError {
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			var emit bool
+			if repeats, emit = h.dedup.check(spanCtx.TraceID().String()+"|"+r.Message, r.Time); !emit {
+				h.recordSuppressed(ctx, "dedup")
+				return nil
+			}
+		}
+	}
+
+	attrs := make([]log.KeyValue, 0, len(h.attrs)+r.NumAttrs()+6) // for the span context and source
+	logAttrs := make([]any, 0, len(h.attrs)*2+r.NumAttrs()*2+12)
 
-	// handler-level attributes
-	for _, a := range h.attrs {
-		attrs = append(attrs, log.String(a.Key, a.Value.String()))
-		logAttrs = append(logAttrs, a.Key, a.Value.Any())
+	// handler-level attributes, qualified by the groups open when they were added
+	for _, ha := range h.attrs {
+		a := ha.attr
+		if !includeAttr(a, r.Level) {
+			continue
+		}
+		a = h.redacted(a)
+		a.Key = ha.prefix + a.Key
+		attrs = append(attrs, logAttr(a))
+		logAttrs = append(logAttrs, a)
 	}
 
-	// record-level attributes
-	r.Attrs(func(a slog.Attr) bool {
-		attrs = append(attrs, log.String(a.Key, a.Value.String()))
-		logAttrs = append(logAttrs, a.Key, a.Value.Any())
+	// record-level attributes, qualified by the open groups
+	r.Attrs(func(ra slog.Attr) bool {
+		for _, a := range inlineAttr(ra) {
+			if !includeAttr(a, r.Level) {
+				continue
+			}
+			a = h.redacted(a)
+			a.Key = h.prefix + a.Key
+			attrs = append(attrs, logAttr(a))
+			logAttrs = append(logAttrs, a)
+
+			// classify error values, e.g. "error" gets a sibling "error.class"
+			if err, ok := a.Value.Any().(error); ok && a.Value.Kind() == slog.KindAny {
+				class := slog.String(a.Key+".class", Classify(err))
+				attrs = append(attrs, logAttr(class))
+				logAttrs = append(logAttrs, class)
+			}
+		}
 		return true
 	})
 
-	// group
-	if h.group != "" {
-		attrs = append(attrs, log.String("group", h.group))
-		logAttrs = append(logAttrs, "group", h.group)
+	if h.ctxAttrs != nil {
+		for _, ca := range h.ctxAttrs(ctx) {
+			for _, a := range inlineAttr(ca) {
+				a = h.redacted(a)
+				attrs = append(attrs, logAttr(a))
+				logAttrs = append(logAttrs, a)
+			}
+		}
+	}
+
+	if h.baggage {
+		for _, m := range baggage.FromContext(ctx).Members() {
+			a := h.redacted(slog.String(m.Key(), m.Value()))
+			a.Key = "baggage." + a.Key
+			attrs = append(attrs, logAttr(a))
+			logAttrs = append(logAttrs, a)
+		}
 	}
 
-	// include span info if present
-	if span := trace.SpanFromContext(ctx); span.IsRecording() {
-		spanCtx := span.SpanContext()
+	if repeats > 0 {
+		attrs = append(attrs, log.Int("log.repeat_count", repeats))
+		logAttrs = append(logAttrs, "log.repeat_count", repeats)
+	}
+
+	// include span info if present; sampled-out spans still carry a valid
+	// context, so their logs stay correlated with the trace ID
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		traceID, spanID, flags := spanCtx.TraceID().String(), spanCtx.SpanID().String(), spanCtx.TraceFlags().String()
 		attrs = append(attrs,
-			log.String("trace_id", spanCtx.TraceID().String()),
-			log.String("span_id", spanCtx.SpanID().String()),
+			log.String("trace_id", traceID),
+			log.String("span_id", spanID),
+			log.String("trace_flags", flags),
 		)
-		logAttrs = append(logAttrs, "trace_id", spanCtx.TraceID().String(), "span_id", spanCtx.SpanID().String())
+		logAttrs = append(logAttrs, "trace_id", traceID, "span_id", spanID, "trace_flags", flags)
+		if state := spanCtx.TraceState(); state.Len() > 0 {
+			attrs = append(attrs, log.String("trace_state", state.String()))
+			logAttrs = append(logAttrs, "trace_state", state.String())
+		}
 	}
 
-	// add source file:line
-	if pc, file, line, ok := runtime.Caller(3); ok {
-		_ = runtime.FuncForPC(pc)
-		source := file + ":" + strconv.Itoa(line)
+	// add source file:line of the logging call site
+	if h.source && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		source := frame.File + ":" + strconv.Itoa(frame.Line)
 		attrs = append(attrs, log.String("source", source))
 		logAttrs = append(logAttrs, "source", source)
 	}
 
-	// map slog.Level to OTEL severity
-	severity := log.SeverityInfo
-	switch r.Level {
-	case slog.LevelDebug:
-		severity = log.SeverityDebug
-	case slog.LevelWarn:
-		severity = log.SeverityWarn
-	case slog.LevelError:
-		severity = log.SeverityError
-	}
+	severity := severityFor(r.Level)
 
 	// build OTEL log record
 	logRecord := log.Record{}
 	logRecord.SetSeverity(severity)
 	logRecord.SetTimestamp(r.Time)
 	logRecord.SetObservedTimestamp(time.Now())
-	logRecord.SetBody(log.StringValue(r.Message))
-	logRecord.AddAttributes(attrs...)
+	if h.mapBody {
+		logRecord.SetBody(log.MapValue(append([]log.KeyValue{log.String("message", r.Message)}, attrs...)...))
+	} else {
+		logRecord.SetBody(log.StringValue(r.Message))
+		logRecord.AddAttributes(attrs...)
+	}
 	logRecord.SetSeverityText(severity.String())
 
-	h.otelLogger.Emit(ctx, logRecord)
+	// detach from cancellation so the final logs of a finished request are not
+	// dropped; values such as the span context are kept
+	h.otelLogger.Emit(context.WithoutCancel(ctx), logRecord)
+	if h.metrics != nil {
+		h.metrics.emitted.Add(ctx, 1, metric.WithAttributes(attribute.String("severity", severity.String())))
+	}
+
+	// forward to the downstream handler
+	if h.next == nil || !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	out := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	out.Add(logAttrs...)
+	return h.next.Handle(ctx, out)
+}
 
-	// emit to terminal logger
-	h.logger.Log(ctx, r.Level, r.Message, logAttrs...)
-	return nil
+// redacted masks the attribute value, or the matching members of a group,
+// when the redaction function selects their key
+func (h *otelHandler) redacted(a slog.Attr) slog.Attr {
+	if h.redact == nil {
+		return a
+	}
+	if h.redact(a.Key) {
+		return slog.String(a.Key, "***")
+	}
+	if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+		group := v.Group()
+		members := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			members[i] = h.redacted(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(members...)}
+	}
+	return a
 }
 
-// WithAttrs returns a new handler with additional attributes
+// sampled applies the log sampling rates, always keeping records of sampled traces
+func (h *otelHandler) sampled(ctx context.Context, level slog.Level) bool {
+	if len(h.sampling) == 0 || trace.SpanContextFromContext(ctx).IsSampled() {
+		return true
+	}
+	rate := 1.0
+	for _, lr := range h.sampling {
+		if level < lr.level {
+			break
+		}
+		rate = lr.rate
+	}
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// recordSuppressed counts a record the handler suppressed for the given reason
+func (h *otelHandler) recordSuppressed(ctx context.Context, reason string) {
+	if h.metrics != nil {
+		h.metrics.suppressed.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+	}
+}
+
+// WithAttrs returns a new handler with additional attributes, nested under the
+// groups open at the time of the call
 func (h *otelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	newAttrs := append([]slog.Attr(nil), h.attrs...)
-	newAttrs = append(newAttrs, attrs...)
-	return &otelHandler{
-		otelLogger: h.otelLogger,
-		logger:     h.logger,
-		attrs:      newAttrs,
-		group:      h.group,
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(make([]handlerAttr, 0, len(h.attrs)+len(attrs)), h.attrs...)
+	for _, ra := range attrs {
+		for _, a := range inlineAttr(ra) {
+			h2.attrs = append(h2.attrs, handlerAttr{prefix: h.prefix, attr: a})
+		}
 	}
+	return &h2
 }
 
-// WithGroup returns a new handler with group set
+// WithGroup returns a new handler that nests subsequent record attributes
+// under name, so keys are emitted as "name.key"
 func (h *otelHandler) WithGroup(name string) slog.Handler {
-	return &otelHandler{
-		otelLogger: h.otelLogger,
-		logger:     h.logger,
-		attrs:      h.attrs,
-		group:      name,
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// WithScope returns a handler emitting under the instrumentation scope name
+// (e.g. "payments.worker") while keeping the attributes and groups of h
+func (h *otelHandler) WithScope(name string) slog.Handler {
+	h2 := *h
+	h2.otelLogger = h.provider.Logger(name)
+	return &h2
+}
+
+// WithScope returns a sub-handler of h emitting under its own instrumentation
+// scope, for per-subsystem scopes within one service. Handlers not created by
+// this package are returned unchanged.
+func WithScope(h slog.Handler, name string) slog.Handler {
+	if sh, ok := h.(interface{ WithScope(string) slog.Handler }); ok {
+		return sh.WithScope(name)
+	}
+	return h
+}
+
+// inlineAttr applies the slog rules for special attributes: empty attributes
+// are dropped and the members of a group with an empty key are inlined
+func inlineAttr(a slog.Attr) []slog.Attr {
+	if a.Equal(slog.Attr{}) {
+		return nil
+	}
+	if a.Key != "" {
+		return []slog.Attr{a}
+	}
+	if v := a.Value.Resolve(); v.Kind() == slog.KindGroup {
+		var attrs []slog.Attr
+		for _, ga := range v.Group() {
+			attrs = append(attrs, inlineAttr(ga)...)
+		}
+		return attrs
+	}
+	return []slog.Attr{a}
+}
+
+// includeAttr reports whether the attribute should be emitted at the given level
+func includeAttr(a slog.Attr, level slog.Level) bool {
+	if a.Key == DebugGroup && a.Value.Kind() == slog.KindGroup {
+		return level <= slog.LevelDebug
+	}
+	return true
+}
+
+// severityFor maps a slog level onto the OTEL severity range. slog levels are
+// spaced four apart like the OTEL severity ranges (Debug=-4, Info=0, Warn=4,
+// Error=8), so offsetting by SeverityInfo maps each named level to the first
+// severity of its range, intermediate levels to the matching numbered
+// severity, and levels above Error+3 to Fatal.
+func severityFor(level slog.Level) log.Severity {
+	severity := log.Severity(int(level) + int(log.SeverityInfo))
+	return min(max(severity, log.SeverityTrace1), log.SeverityFatal4)
+}
+
+// logAttr converts a slog attribute to an OTEL log attribute
+func logAttr(a slog.Attr) log.KeyValue {
+	return log.KeyValue{Key: a.Key, Value: logValue(a.Value)}
+}
+
+// logValue converts a slog value to the OTEL log value of the matching type,
+// falling back to its string form for kinds without an equivalent
+func logValue(v slog.Value) log.Value {
+	switch v = v.Resolve(); v.Kind() {
+	case slog.KindString:
+		return log.StringValue(v.String())
+	case slog.KindInt64:
+		return log.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return log.Int64Value(int64(u))
+		}
+	case slog.KindFloat64:
+		return log.Float64Value(v.Float64())
+	case slog.KindBool:
+		return log.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return log.Int64Value(v.Duration().Nanoseconds())
+	case slog.KindTime:
+		return log.Int64Value(v.Time().UnixNano())
+	case slog.KindGroup:
+		group := v.Group()
+		kvs := make([]log.KeyValue, 0, len(group))
+		for _, a := range group {
+			kvs = append(kvs, logAttr(a))
+		}
+		return log.MapValue(kvs...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case []byte:
+			return log.BytesValue(x)
+		case error:
+			return log.StringValue(x.Error())
+		}
 	}
+	return log.StringValue(v.String())
 }
@@ -0,0 +1,303 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// memoryLogExporter keeps exported log records in memory
+type memoryLogExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryLogExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryLogExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryLogExporter) ForceFlush(context.Context) error { return nil }
+
+// Records returns the records exported so far
+func (e *memoryLogExporter) Records() []sdklog.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+// newTestHandler returns an OTEL-only handler exporting synchronously to the
+// returned exporter
+func newTestHandler(t testing.TB, opts ...HandlerOption) (slog.Handler, *memoryLogExporter) {
+	t.Helper()
+	exporter := &memoryLogExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	h, err := WrapHandler(nil, "test", append([]HandlerOption{WithLoggerProvider(provider)}, opts...)...)
+	if err != nil {
+		t.Fatalf("WrapHandler: %v", err)
+	}
+	return h, exporter
+}
+
+// onlyRecord returns the single exported record
+func onlyRecord(t *testing.T, exporter *memoryLogExporter) sdklog.Record {
+	t.Helper()
+	records := exporter.Records()
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+	return records[0]
+}
+
+// recordAttr returns the value of the record attribute with the key
+func recordAttr(r sdklog.Record, key string) (log.Value, bool) {
+	var value log.Value
+	var found bool
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		if kv.Key == key {
+			value, found = kv.Value, true
+			return false
+		}
+		return true
+	})
+	return value, found
+}
+
+func TestHandlerSource(t *testing.T) {
+	h, exporter := newTestHandler(t)
+	logger := slog.New(h).With("component", "test")
+
+	_, file, line, _ := runtime.Caller(0)
+	logger.InfoContext(context.Background(), "located")
+
+	source, ok := recordAttr(onlyRecord(t, exporter), "source")
+	if !ok {
+		t.Fatal("record has no source attribute")
+	}
+	if want := fmt.Sprintf("%s:%d", file, line+1); source.AsString() != want {
+		t.Errorf("source = %q, want %q", source.AsString(), want)
+	}
+}
+
+func TestHandlerSourceDisabled(t *testing.T) {
+	h, exporter := newTestHandler(t, WithSource(false))
+	slog.New(h).Info("unlocated")
+
+	if _, ok := recordAttr(onlyRecord(t, exporter), "source"); ok {
+		t.Error("record has a source attribute although WithSource(false) was set")
+	}
+}
+
+// upper is a slog.LogValuer resolving to its upper-case form
+type upper string
+
+func (u upper) LogValue() slog.Value { return slog.StringValue(strings.ToUpper(string(u))) }
+
+func TestHandlerAttributeKinds(t *testing.T) {
+	now := time.Date(2025, 4, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want log.Value
+	}{
+		{"string", slog.String("v", "text"), log.StringValue("text")},
+		{"int64", slog.Int64("v", -42), log.Int64Value(-42)},
+		{"uint64", slog.Uint64("v", 42), log.Int64Value(42)},
+		{"uint64 overflow", slog.Uint64("v", math.MaxUint64), log.StringValue("18446744073709551615")},
+		{"float64", slog.Float64("v", 1.5), log.Float64Value(1.5)},
+		{"bool", slog.Bool("v", true), log.BoolValue(true)},
+		{"duration", slog.Duration("v", 1500*time.Millisecond), log.Int64Value(1_500_000_000)},
+		{"time", slog.Time("v", now), log.Int64Value(now.UnixNano())},
+		{"group", slog.Group("v", "a", 1, "b", "x"), log.MapValue(log.Int64("a", 1), log.String("b", "x"))},
+		{"bytes", slog.Any("v", []byte("raw")), log.BytesValue([]byte("raw"))},
+		{"error", slog.Any("v", errors.New("boom")), log.StringValue("boom")},
+		{"log valuer", slog.Any("v", upper("quiet")), log.StringValue("QUIET")},
+		{"other any", slog.Any("v", []int{1, 2}), log.StringValue("[1 2]")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, exporter := newTestHandler(t, WithSource(false))
+			slog.New(h).Info("kinds", tt.attr)
+
+			got, ok := recordAttr(onlyRecord(t, exporter), "v")
+			if !ok {
+				t.Fatal("record has no v attribute")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("v = %v (%v), want %v (%v)", got, got.Kind(), tt.want, tt.want.Kind())
+			}
+		})
+	}
+}
+
+func TestHandlerNestedGroups(t *testing.T) {
+	var terminal bytes.Buffer
+	exporter := &memoryLogExporter{}
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(exporter)))
+	defer provider.Shutdown(context.Background())
+	h, err := WrapHandler(slog.NewJSONHandler(&terminal, nil), "test", WithLoggerProvider(provider), WithSource(false))
+	if err != nil {
+		t.Fatalf("WrapHandler: %v", err)
+	}
+
+	slog.New(h).
+		With("k", "root").
+		WithGroup("outer").
+		With("k", "outer").
+		WithGroup("inner").
+		Info("nested", "k", "inner", slog.Group("g", "k", "group"))
+
+	record := onlyRecord(t, exporter)
+	for key, value := range map[string]string{"k": "root", "outer.k": "outer", "outer.inner.k": "inner"} {
+		if got, ok := recordAttr(record, key); !ok || got.AsString() != value {
+			t.Errorf("%s = %v, want %q", key, got, value)
+		}
+	}
+	group, ok := recordAttr(record, "outer.inner.g")
+	if want := log.MapValue(log.String("k", "group")); !ok || !group.Equal(want) {
+		t.Errorf("outer.inner.g = %v, want %v", group, want)
+	}
+
+	var line map[string]any
+	if err := json.Unmarshal(terminal.Bytes(), &line); err != nil {
+		t.Fatalf("terminal output %q: %v", terminal.String(), err)
+	}
+	for key, value := range map[string]string{"k": "root", "outer.k": "outer", "outer.inner.k": "inner"} {
+		if line[key] != value {
+			t.Errorf("terminal %s = %v, want %q", key, line[key], value)
+		}
+	}
+}
+
+func TestHandlerSeverity(t *testing.T) {
+	tests := []struct {
+		level slog.Level
+		want  log.Severity
+	}{
+		{slog.LevelDebug - 12, log.SeverityTrace1},
+		{slog.LevelDebug - 4, log.SeverityTrace1},
+		{slog.LevelDebug - 1, log.SeverityTrace4},
+		{slog.LevelDebug, log.SeverityDebug1},
+		{slog.LevelDebug + 2, log.SeverityDebug3},
+		{slog.LevelInfo, log.SeverityInfo1},
+		{slog.LevelInfo + 1, log.SeverityInfo2},
+		{slog.LevelWarn, log.SeverityWarn1},
+		{slog.LevelError, log.SeverityError1},
+		{slog.LevelError + 3, log.SeverityError4},
+		{slog.LevelError + 4, log.SeverityFatal1},
+		{slog.LevelError + 7, log.SeverityFatal4},
+		{slog.LevelError + 100, log.SeverityFatal4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			h, exporter := newTestHandler(t)
+			slog.New(h).Log(context.Background(), tt.level, "leveled")
+
+			record := onlyRecord(t, exporter)
+			if record.Severity() != tt.want {
+				t.Errorf("severity = %v, want %v", record.Severity(), tt.want)
+			}
+			if record.SeverityText() != tt.want.String() {
+				t.Errorf("severity text = %q, want %q", record.SeverityText(), tt.want.String())
+			}
+		})
+	}
+}
+
+func TestHandlerSamplingKeepsSampledTraces(t *testing.T) {
+	spanCtx := func(flags trace.TraceFlags) context.Context {
+		return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+		}))
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int
+	}{
+		{"no trace", context.Background(), 0},
+		{"unsampled trace", spanCtx(0), 0},
+		{"sampled trace", spanCtx(trace.FlagsSampled), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, exporter := newTestHandler(t, WithLogSampling(map[slog.Level]float64{slog.LevelInfo: 0}))
+			slog.New(h).InfoContext(tt.ctx, "sampled")
+
+			if got := len(exporter.Records()); got != tt.want {
+				t.Errorf("got %d records, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerMetricsSuppressed(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	h, exporter := newTestHandler(t,
+		WithHandlerMetrics(provider.Meter("test")),
+		WithLogSampling(map[slog.Level]float64{slog.LevelInfo: 0, slog.LevelWarn: 1}),
+	)
+	logger := slog.New(h)
+	logger.Info("sampled out")
+	logger.Warn("kept")
+
+	if got := len(exporter.Records()); got != 1 {
+		t.Errorf("got %d records, want 1", got)
+	}
+	if got := sumByAttribute(t, reader, "otel_client_log_records_suppressed", "reason"); got["sampling"] != 1 || len(got) != 1 {
+		t.Errorf("suppressed = %v, want 1 for sampling", got)
+	}
+}
+
+// discardLogExporter drops exported log records
+type discardLogExporter struct{}
+
+func (discardLogExporter) Export(context.Context, []sdklog.Record) error { return nil }
+func (discardLogExporter) Shutdown(context.Context) error                { return nil }
+func (discardLogExporter) ForceFlush(context.Context) error              { return nil }
+
+func BenchmarkHandlerHandle(b *testing.B) {
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(discardLogExporter{})))
+	defer provider.Shutdown(context.Background())
+	h, err := WrapHandler(nil, "bench", WithLoggerProvider(provider), WithSource(false))
+	if err != nil {
+		b.Fatalf("WrapHandler: %v", err)
+	}
+	logger := slog.New(h).With("service", "bench")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	b.ReportAllocs()
+	for b.Loop() {
+		logger.InfoContext(ctx, "request handled", "status", 200, "path", "/hello", "duration", time.Millisecond)
+	}
+}
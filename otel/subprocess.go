@@ -0,0 +1,44 @@
+package otel
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Environment variables carrying W3C trace context across process boundaries
+const (
+	traceParentEnv = "TRACEPARENT"
+	traceStateEnv  = "TRACESTATE"
+)
+
+// TraceEnvFromContext returns TRACEPARENT (and TRACESTATE when set) entries for
+// the span in ctx, ready to append to exec.Cmd.Env
+func TraceEnvFromContext(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	env := make([]string, 0, 2)
+	if v := carrier.Get("traceparent"); v != "" {
+		env = append(env, traceParentEnv+"="+v)
+	}
+	if v := carrier.Get("tracestate"); v != "" {
+		env = append(env, traceStateEnv+"="+v)
+	}
+	return env
+}
+
+// ContextFromEnv returns ctx carrying the remote span context read from the
+// TRACEPARENT and TRACESTATE environment variables, so a subprocess continues
+// its parent's trace
+func ContextFromEnv(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	if v := os.Getenv(traceParentEnv); v != "" {
+		carrier.Set("traceparent", v)
+	}
+	if v := os.Getenv(traceStateEnv); v != "" {
+		carrier.Set("tracestate", v)
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
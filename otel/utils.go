@@ -1,50 +1,344 @@
 package otel
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"runtime/debug"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
-// RecordTraceError records an error in a span
-func RecordTraceError(err error, serviceName string, span trace.Span) {
-	span.RecordError(err)
-	span.SetStatus(codes.Error, err.Error())
-	span.AddEvent(serviceName, trace.WithAttributes(
-		attribute.String("error", err.Error())))
+// ErrorOption configures RecordTraceError
+type ErrorOption func(*errorConfig)
+
+// errorConfig holds which parts of an error RecordTraceError records
+type errorConfig struct {
+	status     bool
+	stackTrace bool
+	event      bool
+}
+
+// WithStatus controls whether the span status is set to Error (default true).
+// Disable it for handled or retried errors that should still be recorded.
+func WithStatus(enabled bool) ErrorOption {
+	return func(c *errorConfig) {
+		c.status = enabled
+	}
+}
+
+// WithStackTrace controls whether the exception event carries
+// exception.stacktrace (default true)
+func WithStackTrace(enabled bool) ErrorOption {
+	return func(c *errorConfig) {
+		c.stackTrace = enabled
+	}
+}
+
+// WithErrorEvent controls whether the event named after the service is added
+// next to the exception event (default true)
+func WithErrorEvent(enabled bool) ErrorOption {
+	return func(c *errorConfig) {
+		c.event = enabled
+	}
+}
+
+// RecordTraceError records an error in a span. The exception event carries
+// exception.type, exception.message and exception.stacktrace, using the stack
+// attached to the error (e.g. by github.com/pkg/errors) when there is one, and
+// the span is tagged with the error.class from Classify. By default it also
+// sets the span status to Error and adds an event named after the service;
+// opts can turn these off.
+func RecordTraceError(err error, serviceName string, span trace.Span, opts ...ErrorOption) {
+	cfg := errorConfig{status: true, stackTrace: true, event: true}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	var recordOpts []trace.EventOption
+	if cfg.stackTrace {
+		recordOpts = append(recordOpts, trace.WithAttributes(semconv.ExceptionStacktrace(stackTrace(err))))
+	}
+	span.RecordError(err, recordOpts...)
+	span.SetAttributes(ErrorClass(err))
+	if cfg.status {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	if cfg.event {
+		span.AddEvent(serviceName, trace.WithAttributes(
+			attribute.String("error", err.Error())))
+	}
+}
+
+// RecordTraceErrorf formats an error with fmt.Errorf, so %w wraps as usual, and
+// records it like RecordTraceError
+func RecordTraceErrorf(span trace.Span, serviceName, format string, args ...any) {
+	RecordTraceError(fmt.Errorf(format, args...), serviceName, span)
+}
+
+// RecordTraceSuccessful records a successful operation in a span; attrs (e.g.
+// rows_affected or cache=hit) are attached to the success event
+func RecordTraceSuccessful(serviceName string, span trace.Span, attrs ...attribute.KeyValue) {
+	RecordTraceSuccessfulMessage(span, "OK", serviceName+" successful", attrs...)
+}
+
+// RecordTraceSuccessfulMessage records a successful operation like
+// RecordTraceSuccessful with a custom status description and event name. Note
+// that the SDK only exports status descriptions of failed spans.
+func RecordTraceSuccessfulMessage(span trace.Span, status, event string, attrs ...attribute.KeyValue) {
+	span.SetStatus(codes.Ok, status)
+	span.AddEvent(event, trace.WithAttributes(attrs...))
+}
+
+// AddSpanEvent adds an event with structured attributes to the span
+func AddSpanEvent(span trace.Span, name string, attrs ...attribute.KeyValue) {
+	span.AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+// AddSpanEventAt adds an event to the span timestamped at t, for occurrences
+// that happened before they were recorded (e.g. a message's enqueue time)
+func AddSpanEventAt(span trace.Span, name string, t time.Time, attrs ...attribute.KeyValue) {
+	span.AddEvent(name, trace.WithTimestamp(t), trace.WithAttributes(attrs...))
+}
+
+// DefaultScopeName is the instrumentation scope used when callers don't name one
+const DefaultScopeName = "otel-client"
+
+// StartSpan creates a new span with the given name; opts such as
+// trace.WithAttributes or trace.WithSpanKind are passed to the tracer
+func StartSpan(ctx context.Context, tracerProvider trace.TracerProvider, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return StartNamedSpan(ctx, tracerProvider, DefaultScopeName, name, opts...)
+}
+
+// StartNamedSpan creates a new span from the tracer of the given instrumentation scope
+func StartNamedSpan(ctx context.Context, tracerProvider trace.TracerProvider, scopeName, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	tracer := tracerProvider.Tracer(scopeName)
+	return tracer.Start(ctx, name, opts...)
 }
 
-// RecordTraceSuccessful records a successful operation in a span
-func RecordTraceSuccessful(serviceName string, span trace.Span) {
-	span.SetStatus(codes.Ok, "OK")
-	span.AddEvent(serviceName + " successful")
+// StartLinkedSpan creates a new span linked to other traces, e.g. one span
+// processing a batch of messages that each carry their own trace context
+func StartLinkedSpan(ctx context.Context, tracerProvider trace.TracerProvider, name string, links ...trace.Link) (context.Context, trace.Span) {
+	return StartSpan(ctx, tracerProvider, name, trace.WithLinks(links...))
 }
 
-// StartSpan creates a new span with the given name
-func StartSpan(ctx context.Context, tracerProvider trace.TracerProvider, name string) (context.Context, trace.Span) {
-	tracer := tracerProvider.Tracer("otel-client")
-	return tracer.Start(ctx, name)
+// LinkFromCarrier builds a link to the remote span context found in carrier by
+// the global propagator. The link's span context is invalid when the carrier
+// holds none, which callers can check with SpanContext.IsValid.
+func LinkFromCarrier(carrier propagation.TextMapCarrier, attrs ...attribute.KeyValue) trace.Link {
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+	return trace.Link{SpanContext: trace.SpanContextFromContext(ctx), Attributes: attrs}
 }
 
-// MetricsRecorder helps create and record metrics for module or API requests
+// TraceIDFromContext returns the hex trace ID of the span in ctx, or "" when
+// there is none, for correlating logs written by non-slog loggers. Like the
+// slog handler it includes spans that were sampled out.
+func TraceIDFromContext(ctx context.Context) string {
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		return spanCtx.TraceID().String()
+	}
+	return ""
+}
+
+// SpanIDFromContext returns the hex span ID of the span in ctx, or "" when
+// there is none
+func SpanIDFromContext(ctx context.Context) string {
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		return spanCtx.SpanID().String()
+	}
+	return ""
+}
+
+// RecordDeadline records the time left before the context deadline as the
+// deadline.remaining_ms span attribute; it does nothing when ctx has no deadline
+func RecordDeadline(ctx context.Context, span trace.Span) {
+	if deadline, ok := ctx.Deadline(); ok {
+		span.SetAttributes(attribute.Int64("deadline.remaining_ms", time.Until(deadline).Milliseconds()))
+	}
+}
+
+// StartSpanWithTimeout creates a new span whose context times out after d. If
+// the deadline is hit before the span ends, the span gets a timeout event and
+// an error status. Call the returned cancel func when the operation completes,
+// like with context.WithTimeout.
+func StartSpanWithTimeout(ctx context.Context, tracerProvider trace.TracerProvider, name string, d time.Duration) (context.Context, trace.Span, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	ctx, span := StartSpan(ctx, tracerProvider, name, trace.WithAttributes(attribute.Int64("timeout_ms", d.Milliseconds())))
+
+	stop := context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			span.AddEvent("timeout")
+			span.SetStatus(codes.Error, "timeout")
+		}
+	})
+	return ctx, span, func() {
+		stop()
+		cancel()
+	}
+}
+
+// stackTrace returns the stack carried by err or, failing that, the current one
+func stackTrace(err error) string {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		// errors exposing a StackTrace method print their stack with %+v
+		if reflect.ValueOf(e).MethodByName("StackTrace").IsValid() {
+			return fmt.Sprintf("%+v", e)
+		}
+	}
+	return string(debug.Stack())
+}
+
+// MetricsRecorder helps create and record metrics for module or API requests.
+// It is safe for concurrent use: lazily created instruments are registered on
+// the meter once per name, so concurrent first calls share one instrument and
+// observable callbacks are never registered twice.
 type MetricsRecorder struct {
+	inFlightName     string
+	requestSizeName  string
+	responseSizeName string
+	errorType        func(error) string
+	attrFilter       attribute.Filter
 	meter            metric.Meter
 	acceptedRequests metric.Int64Counter
 	failedRequests   metric.Int64Counter
+	rejectedRequests metric.Int64Counter
 	latency          metric.Float64Histogram
+	latencyMillis    bool
+
+	mu          sync.RWMutex
+	instruments map[string]any // custom instruments by name
+}
+
+// RecorderOption configures a MetricsRecorder
+type RecorderOption func(*recorderConfig)
+
+// recorderConfig holds the MetricsRecorder settings
+type recorderConfig struct {
+	latencyBuckets []float64
+	names          MetricNames
+	errorType      func(error) string
+	attrFilter     attribute.Filter
+	latencyMillis  bool
 }
 
-// NewMetricsRecorder creates a new metrics recorder for a service
-func NewMetricsRecorder(meterProvider metric.MeterProvider, serviceName string) (*MetricsRecorder, error) {
-	meter := meterProvider.Meter(serviceName)
+// MetricNames holds the instrument names used by a MetricsRecorder. Empty
+// fields keep the default "{service}_module_..." names.
+type MetricNames struct {
+	Accepted string // default {service}_module_requests_accepted_total
+	Failed   string // default {service}_module_requests_failed_total
+	Rejected string // default {service}_module_requests_rejected_total
+	Latency  string // default {service}_module_request_duration_seconds
+	InFlight string // default {service}_module_requests_in_flight
+
+	RequestSize  string // default {service}_module_request_size_bytes
+	ResponseSize string // default {service}_module_response_size_bytes
+}
+
+// defaultMetricNames returns the default instrument names for a service
+func defaultMetricNames(serviceName string) MetricNames {
+	return MetricNames{
+		Accepted: fmt.Sprintf("%s_module_requests_accepted_total", serviceName),
+		Failed:   fmt.Sprintf("%s_module_requests_failed_total", serviceName),
+		Rejected: fmt.Sprintf("%s_module_requests_rejected_total", serviceName),
+		Latency:  fmt.Sprintf("%s_module_request_duration_seconds", serviceName),
+		InFlight: fmt.Sprintf("%s_module_requests_in_flight", serviceName),
+
+		RequestSize:  fmt.Sprintf("%s_module_request_size_bytes", serviceName),
+		ResponseSize: fmt.Sprintf("%s_module_response_size_bytes", serviceName),
+	}
+}
+
+// WithMetricNames overrides the instrument names, e.g. to follow a dotted
+// naming convention such as "checkout.requests.accepted"
+func WithMetricNames(names MetricNames) RecorderOption {
+	return func(c *recorderConfig) {
+		c.names.Accepted = cmp.Or(names.Accepted, c.names.Accepted)
+		c.names.Failed = cmp.Or(names.Failed, c.names.Failed)
+		c.names.Rejected = cmp.Or(names.Rejected, c.names.Rejected)
+		c.names.Latency = cmp.Or(names.Latency, c.names.Latency)
+		c.names.InFlight = cmp.Or(names.InFlight, c.names.InFlight)
+		c.names.RequestSize = cmp.Or(names.RequestSize, c.names.RequestSize)
+		c.names.ResponseSize = cmp.Or(names.ResponseSize, c.names.ResponseSize)
+	}
+}
+
+// WithLatencyBuckets sets explicit bucket boundaries, in seconds (milliseconds
+// with WithLatencyMilliseconds), for the latency histogram instead of the SDK
+// defaults, which are too coarse for sub-millisecond APIs. The boundaries are
+// an advisory hint honored by the SDK meter provider without further setup; a
+// view matching the histogram configured on the provider (Config.Views) takes
+// precedence over them.
+func WithLatencyBuckets(bounds ...float64) RecorderOption {
+	return func(c *recorderConfig) {
+		c.latencyBuckets = bounds
+	}
+}
+
+// WithLatencyMilliseconds records latency in milliseconds (unit "ms") instead
+// of seconds, for millisecond-oriented dashboards. The default histogram name
+// then ends in _milliseconds and WithLatencyBuckets takes milliseconds.
+func WithLatencyMilliseconds() RecorderOption {
+	return func(c *recorderConfig) {
+		c.latencyMillis = true
+	}
+}
+
+// WithErrorTypeFunc registers the classifier RecordError uses for the error.type
+// attribute instead of the error's concrete Go type
+func WithErrorTypeFunc(fn func(error) string) RecorderOption {
+	return func(c *recorderConfig) {
+		c.errorType = fn
+	}
+}
+
+// WithAttributeAllowlist drops every attribute whose key isn't listed from the
+// measurements of the recorder, capping the cardinality of its series (e.g.
+// keep "method" and "status" but not a raw "endpoint"). Attributes added by the
+// recorder itself, such as error.type from RecordError, must be listed too. To
+// apply an allowlist to every instrument of the provider instead, add a view to
+// Config.Views:
+//
+//	sdkmetric.NewView(
+//		sdkmetric.Instrument{Name: "*"},
+//		sdkmetric.Stream{AttributeFilter: attribute.NewAllowKeysFilter(keys...)},
+//	)
+func WithAttributeAllowlist(keys ...attribute.Key) RecorderOption {
+	return func(c *recorderConfig) {
+		c.attrFilter = attribute.NewAllowKeysFilter(keys...)
+	}
+}
+
+// NewMetricsRecorder creates a new metrics recorder for a service. Each call
+// registers its instruments again; use Otel.MetricsRecorder to share one
+// recorder per name.
+func NewMetricsRecorder(meterProvider metric.MeterProvider, serviceName string, opts ...RecorderOption) (*MetricsRecorder, error) {
+	return NewMetricsRecorderFromMeter(meterProvider.Meter(serviceName), serviceName, opts...)
+}
+
+// NewMetricsRecorderFromMeter creates a metrics recorder whose instruments are
+// created on an existing meter, e.g. one with its own scope attributes.
+// namePrefix replaces the service name in the default instrument names.
+func NewMetricsRecorderFromMeter(meter metric.Meter, namePrefix string, opts ...RecorderOption) (*MetricsRecorder, error) {
+	cfg := recorderConfig{
+		names:     defaultMetricNames(namePrefix),
+		errorType: func(err error) string { return fmt.Sprintf("%T", err) },
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	acceptedRequests, err := meter.Int64Counter(
-		fmt.Sprintf("%s_module_requests_accepted_total", serviceName),
+		cfg.names.Accepted,
 		metric.WithDescription("Total number of requests accepted by a module or API"),
 	)
 	if err != nil {
@@ -52,40 +346,201 @@ func NewMetricsRecorder(meterProvider metric.MeterProvider, serviceName string)
 	}
 
 	failedRequests, err := meter.Int64Counter(
-		fmt.Sprintf("%s_module_requests_failed_total", serviceName),
+		cfg.names.Failed,
 		metric.WithDescription("Total number of requests failed by a module or API"),
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	latency, err := meter.Float64Histogram(
-		fmt.Sprintf("%s_module_request_duration_seconds", serviceName),
+	rejectedRequests, err := meter.Int64Counter(
+		cfg.names.Rejected,
+		metric.WithDescription("Total number of requests rejected by a module or API due to load shedding or concurrency limits"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	latencyOpts := []metric.Float64HistogramOption{
 		metric.WithDescription("Request processing latency in seconds for a module or API"),
+		metric.WithUnit("s"),
+	}
+	if cfg.latencyMillis {
+		if cfg.names.Latency == defaultMetricNames(namePrefix).Latency {
+			cfg.names.Latency = fmt.Sprintf("%s_module_request_duration_milliseconds", namePrefix)
+		}
+		latencyOpts = []metric.Float64HistogramOption{
+			metric.WithDescription("Request processing latency in milliseconds for a module or API"),
+			metric.WithUnit("ms"),
+		}
+	}
+	if len(cfg.latencyBuckets) > 0 {
+		latencyOpts = append(latencyOpts, metric.WithExplicitBucketBoundaries(cfg.latencyBuckets...))
+	}
+	latency, err := meter.Float64Histogram(
+		cfg.names.Latency,
+		latencyOpts...,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	return &MetricsRecorder{
+		inFlightName:     cfg.names.InFlight,
+		requestSizeName:  cfg.names.RequestSize,
+		responseSizeName: cfg.names.ResponseSize,
+		errorType:        cfg.errorType,
+		attrFilter:       cfg.attrFilter,
 		meter:            meter,
 		acceptedRequests: acceptedRequests,
 		failedRequests:   failedRequests,
+		rejectedRequests: rejectedRequests,
 		latency:          latency,
+		latencyMillis:    cfg.latencyMillis,
+		instruments:      make(map[string]any),
 	}, nil
 }
 
 // RecordAcceptedRequest records a successful request for a module or API
 func (m *MetricsRecorder) RecordAcceptedRequest(ctx context.Context, attributes ...attribute.KeyValue) {
-	m.acceptedRequests.Add(ctx, 1, metric.WithAttributes(attributes...))
+	m.acceptedRequests.Add(ctx, 1, m.attributes(attributes))
 }
 
 // RecordFailedRequest records a failed request for a module or API
 func (m *MetricsRecorder) RecordFailedRequest(ctx context.Context, attributes ...attribute.KeyValue) {
-	m.failedRequests.Add(ctx, 1, metric.WithAttributes(attributes...))
+	m.failedRequests.Add(ctx, 1, m.attributes(attributes))
+}
+
+// RecordError records a failed request caused by err, tagged with error.type
+// (the error's Go type or the WithErrorTypeFunc classifier) and error.class
+func (m *MetricsRecorder) RecordError(ctx context.Context, err error, attributes ...attribute.KeyValue) {
+	attributes = append(attributes,
+		attribute.String("error.type", m.errorType(err)),
+		ErrorClass(err),
+	)
+	m.RecordFailedRequest(ctx, attributes...)
 }
 
-// RecordLatency records request latency for a module or API
+// RecordRejectedRequest records a request rejected by a concurrency limit or load
+// shedding, counted separately from application failures
+func (m *MetricsRecorder) RecordRejectedRequest(ctx context.Context, attributes ...attribute.KeyValue) {
+	m.rejectedRequests.Add(ctx, 1, m.attributes(attributes))
+}
+
+// RecordLatency records request latency for a module or API, in seconds or in
+// milliseconds with WithLatencyMilliseconds. Pass the context holding the request
+// span so the measurement can carry it as an exemplar
 func (m *MetricsRecorder) RecordLatency(ctx context.Context, duration time.Duration, attributes ...attribute.KeyValue) {
-	m.latency.Record(ctx, duration.Seconds(), metric.WithAttributes(attributes...))
+	value := duration.Seconds()
+	if m.latencyMillis {
+		value = float64(duration) / float64(time.Millisecond)
+	}
+	m.latency.Record(ctx, value, m.attributes(attributes))
+}
+
+// RecordInFlight adjusts the number of in-flight requests by delta: call it with
+// +1 when a request starts and -1 when it ends, using the same attributes. The
+// up-down counter is created on first use.
+func (m *MetricsRecorder) RecordInFlight(ctx context.Context, delta int64, attributes ...attribute.KeyValue) {
+	inFlight, err := cachedInstrument(m, m.inFlightName, func() (metric.Int64UpDownCounter, error) {
+		return m.meter.Int64UpDownCounter(m.inFlightName,
+			metric.WithDescription("Number of requests currently being processed by a module or API"),
+		)
+	})
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	inFlight.Add(ctx, delta, m.attributes(attributes))
+}
+
+// attributes returns the measurement attributes, restricted to the allowlist if any
+func (m *MetricsRecorder) attributes(attrs []attribute.KeyValue) metric.MeasurementOption {
+	if m.attrFilter == nil {
+		return metric.WithAttributes(attrs...)
+	}
+	set, _ := attribute.NewSetWithFiltered(attrs, m.attrFilter)
+	return metric.WithAttributeSet(set)
+}
+
+// RecordRequestSize records the size of a request payload in bytes. The
+// histogram is created on first use.
+func (m *MetricsRecorder) RecordRequestSize(ctx context.Context, bytes int64, attributes ...attribute.KeyValue) {
+	m.recordSize(ctx, m.requestSizeName, "Size of request payloads received by a module or API", bytes, attributes)
+}
+
+// RecordResponseSize records the size of a response payload in bytes. The
+// histogram is created on first use.
+func (m *MetricsRecorder) RecordResponseSize(ctx context.Context, bytes int64, attributes ...attribute.KeyValue) {
+	m.recordSize(ctx, m.responseSizeName, "Size of response payloads sent by a module or API", bytes, attributes)
+}
+
+// recordSize records bytes on the payload size histogram with the given name
+func (m *MetricsRecorder) recordSize(ctx context.Context, name, description string, bytes int64, attributes []attribute.KeyValue) {
+	size, err := cachedInstrument(m, name, func() (metric.Int64Histogram, error) {
+		return m.meter.Int64Histogram(name,
+			metric.WithDescription(description),
+			metric.WithUnit("By"),
+		)
+	})
+	if err != nil {
+		otel.Handle(err)
+		return
+	}
+	size.Record(ctx, bytes, m.attributes(attributes))
+}
+
+// Counter returns the custom counter with the given name, creating it on the
+// recorder's meter on first use
+func (m *MetricsRecorder) Counter(name, description string) (metric.Int64Counter, error) {
+	return cachedInstrument(m, name, func() (metric.Int64Counter, error) {
+		return m.meter.Int64Counter(name, metric.WithDescription(description))
+	})
+}
+
+// Gauge returns the custom observable gauge with the given name, creating it on
+// first use with callback reporting its value at each collection. Later calls
+// with the same name return the existing gauge and ignore callback.
+func (m *MetricsRecorder) Gauge(name, description string, callback metric.Float64Callback) (metric.Float64ObservableGauge, error) {
+	return cachedInstrument(m, name, func() (metric.Float64ObservableGauge, error) {
+		return m.meter.Float64ObservableGauge(name,
+			metric.WithDescription(description),
+			metric.WithFloat64Callback(callback),
+		)
+	})
+}
+
+// cachedInstrument returns the instrument cached under name or creates and caches it
+func cachedInstrument[T any](m *MetricsRecorder, name string, create func() (T, error)) (T, error) {
+	// fast path for the hot recording calls once the instrument exists
+	m.mu.RLock()
+	cached, ok := m.instruments[name]
+	m.mu.RUnlock()
+	if ok {
+		return assertInstrument[T](name, cached)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// another goroutine may have created it between the two locks
+	if cached, ok := m.instruments[name]; ok {
+		return assertInstrument[T](name, cached)
+	}
+
+	instrument, err := create()
+	if err != nil {
+		return instrument, err
+	}
+	m.instruments[name] = instrument
+	return instrument, nil
+}
+
+// assertInstrument converts a cached instrument to the requested type
+func assertInstrument[T any](name string, cached any) (T, error) {
+	instrument, ok := cached.(T)
+	if !ok {
+		return instrument, fmt.Errorf("otel: instrument %q already registered as %T", name, cached)
+	}
+	return instrument, nil
 }
@@ -0,0 +1,63 @@
+package otel
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricsRecorderConcurrentCounter(t *testing.T) {
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(ctx)
+
+	recorder, err := NewMetricsRecorder(provider, "test")
+	if err != nil {
+		t.Fatalf("NewMetricsRecorder: %v", err)
+	}
+
+	const goroutines, adds = 50, 100
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range adds {
+				counter, err := recorder.Counter("jobs_total", "Jobs processed")
+				if err != nil {
+					t.Errorf("Counter: %v", err)
+					return
+				}
+				counter.Add(ctx, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	var total int64
+	var streams int
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "jobs_total" {
+				streams++
+				for _, dp := range sum.DataPoints {
+					total += dp.Value
+				}
+			}
+		}
+	}
+	if streams != 1 {
+		t.Errorf("got %d jobs_total streams, want 1", streams)
+	}
+	if total != goroutines*adds {
+		t.Errorf("jobs_total = %d, want %d", total, goroutines*adds)
+	}
+}